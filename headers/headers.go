@@ -39,6 +39,18 @@ func (h *Headers) Del(key string) {
 	delete(headerMap, key)
 }
 
+// HasToken reports whether the comma-separated value of a header contains token,
+// compared case-insensitively. E.g : "Connection: keep-alive, Upgrade"
+func (h Headers) HasToken(key string, token string) bool {
+	for _, part := range strings.Split(h.Get(key), ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (h *Headers) ParseHeader(bytes []byte) (bytesConsumed int, doubleCrlfFlag bool, err error) {
 	str := string(bytes)
 
@@ -81,7 +93,6 @@ func GetDefaultHeaders(contentLength int) Headers {
 	h := NewHeaders()
 
 	h.Add("content-length", strconv.Itoa(contentLength))
-	h.Add("connection", "close")
 	h.Add("Content-Type", "text/plain")
 
 	return h
@@ -0,0 +1,135 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bodyReader streams the body of a request from the connection as the handler reads it.
+// The parser appends every piece of body to Request.Body, so the reader moves those
+// bytes out of it after each step.
+type bodyReader struct {
+	rr      *RequestReader
+	req     *Request
+	pending []byte
+	closed  bool
+}
+
+func (br *bodyReader) Read(p []byte) (int, error) {
+	if br.closed {
+		return 0, errors.New("read on closed body")
+	}
+
+	for len(br.pending) == 0 {
+		if br.req.State == Done {
+			return 0, io.EOF
+		}
+
+		err := br.rr.advance(br.req)
+		if err != nil {
+			return 0, err
+		}
+
+		br.pending = append(br.pending, br.req.Body...)
+		br.req.Body = br.req.Body[:0]
+	}
+
+	n := copy(p, br.pending)
+	br.pending = br.pending[n:]
+
+	return n, nil
+}
+
+// Close stops the handler from reading the body, the unread part is discarded
+// by the RequestReader before parsing the next request.
+func (br *bodyReader) Close() error {
+	br.closed = true
+	return nil
+}
+
+// ReadBody reads the rest of a streamed body into Body, so handlers can decide to
+// buffer it. It does nothing if the body is already buffered.
+func (req *Request) ReadBody() error {
+	br, ok := req.BodyReader.(*bodyReader)
+	if !ok {
+		return nil
+	}
+
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+
+	req.Body = body
+	req.BodyReader = io.NopCloser(bytes.NewReader(req.Body))
+
+	return nil
+}
+
+// Peek blocks until the first byte of the next request is available, so callers can
+// tell a connection waiting for a request apart from one in the middle of sending it.
+func (rr *RequestReader) Peek() error {
+	err := rr.finishStreaming()
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, bufferSize)
+
+	for len(rr.buffered) == 0 {
+		if rr.eof {
+			return io.EOF
+		}
+
+		nBytesRead, err := rr.reader.Read(buffer)
+
+		if nBytesRead > 0 {
+			rr.buffered = append(rr.buffered, buffer[:nBytesRead]...)
+		}
+
+		if errors.Is(err, io.EOF) {
+			rr.eof = true
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("error reading from io.Reader: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Whatever the handler didn't read from a streamed body is still on the wire
+func (rr *RequestReader) finishStreaming() error {
+	if rr.streaming == nil {
+		return nil
+	}
+
+	err := rr.discardBody(rr.streaming)
+	rr.streaming = nil
+	if err != nil {
+		return fmt.Errorf("error discarding unread body: %w", err)
+	}
+
+	return nil
+}
+
+func (rr *RequestReader) discardBody(req *Request) error {
+	for req.State != Done {
+		err := rr.advance(req)
+		if err != nil {
+			return err
+		}
+
+		req.Body = req.Body[:0]
+	}
+
+	return nil
+}
+
+func isReadingBody(state int) bool {
+	return state > ReadingHeader && state < Done
+}
@@ -0,0 +1,22 @@
+package request
+
+import "errors"
+
+// Errors returned by RequestReader when a request can't be parsed. They are wrapped
+// with more details, use errors.Is to tell them apart. A connection closed in the
+// middle of a request is reported as io.ErrUnexpectedEOF.
+var (
+	ErrMalformedRequestLine        = errors.New("malformed request line")
+	ErrUnsupportedVersion          = errors.New("unsupported http version")
+	ErrMalformedHeader             = errors.New("malformed header")
+	ErrMalformedBody               = errors.New("malformed body")
+	ErrBodyLengthMismatch          = errors.New("body shorter than content-length")
+	ErrUnsupportedTransferEncoding = errors.New("unsupported transfer-encoding")
+	ErrRequestLineTooLong          = errors.New("request line too long")
+	ErrHeadersTooLarge             = errors.New("request headers too large")
+	ErrBodyTooLarge                = errors.New("request body too large")
+)
+
+// ErrPathParamNotFound is returned by the typed path parameter accessors, e.g : PathParamInt,
+// when the route has no parameter with that name
+var ErrPathParamNotFound = errors.New("path param not found")
@@ -0,0 +1,86 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits bounds the size of the requests accepted by a RequestReader, 0 means no limit
+type Limits struct {
+	// Length of the request line, without the CRLF
+	MaxRequestLineBytes int
+
+	// Total length of the header section, trailers of a chunked body included
+	MaxHeaderBytes int
+
+	// Number of header lines, trailers of a chunked body included
+	MaxHeaderCount int
+
+	// Length of the body, after removing the chunked encoding
+	MaxBodyBytes int
+}
+
+const (
+	DefaultMaxRequestLineBytes = 8 << 10
+	DefaultMaxHeaderBytes      = 1 << 20
+	DefaultMaxHeaderCount      = 100
+	DefaultMaxBodyBytes        = 10 << 20
+)
+
+// Chunk size lines, extensions included, are bounded whatever the Limits as they
+// count towards neither the headers nor the body
+const maxChunkSizeLineBytes = 4 << 10
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxRequestLineBytes: DefaultMaxRequestLineBytes,
+		MaxHeaderBytes:      DefaultMaxHeaderBytes,
+		MaxHeaderCount:      DefaultMaxHeaderCount,
+		MaxBodyBytes:        DefaultMaxBodyBytes,
+	}
+}
+
+// checkRequestLine is called with every byte buffered while the request line is incomplete
+func (l Limits) checkRequestLine(bytes []byte) error {
+	if l.MaxRequestLineBytes <= 0 {
+		return nil
+	}
+
+	lineLength := strings.Index(string(bytes), crlf)
+	if lineLength == -1 {
+		lineLength = len(bytes)
+	}
+
+	if lineLength > l.MaxRequestLineBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrRequestLineTooLong, l.MaxRequestLineBytes)
+	}
+
+	return nil
+}
+
+// checkHeaders is called after every attempt to parse a header line. bytesConsumed is 0
+// if the line is incomplete, in which case every byte buffered belongs to it.
+func (l Limits) checkHeaders(req *Request, bytes []byte, bytesConsumed int) error {
+	pending := bytesConsumed
+	if pending == 0 {
+		pending = len(bytes)
+	}
+
+	if l.MaxHeaderBytes > 0 && req.headerBytes+pending > l.MaxHeaderBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrHeadersTooLarge, l.MaxHeaderBytes)
+	}
+
+	if l.MaxHeaderCount > 0 && bytesConsumed > 0 && req.headerCount >= l.MaxHeaderCount {
+		return fmt.Errorf("%w: more than %d fields", ErrHeadersTooLarge, l.MaxHeaderCount)
+	}
+
+	return nil
+}
+
+func (l Limits) checkBody(bodyLength int) error {
+	if l.MaxBodyBytes > 0 && bodyLength > l.MaxBodyBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, l.MaxBodyBytes)
+	}
+
+	return nil
+}
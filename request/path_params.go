@@ -0,0 +1,49 @@
+package request
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// PathParamInt returns a path parameter as an int, e.g : for routes like /users/{id:int}
+func (req *Request) PathParamInt(paramName string) (int, error) {
+	return parsePathParam(req, paramName, strconv.Atoi)
+}
+
+func (req *Request) PathParamInt64(paramName string) (int64, error) {
+	return parsePathParam(req, paramName, func(value string) (int64, error) {
+		return strconv.ParseInt(value, 10, 64)
+	})
+}
+
+func (req *Request) PathParamUint64(paramName string) (uint64, error) {
+	return parsePathParam(req, paramName, func(value string) (uint64, error) {
+		return strconv.ParseUint(value, 10, 64)
+	})
+}
+
+func (req *Request) PathParamFloat64(paramName string) (float64, error) {
+	return parsePathParam(req, paramName, func(value string) (float64, error) {
+		return strconv.ParseFloat(value, 64)
+	})
+}
+
+func (req *Request) PathParamBool(paramName string) (bool, error) {
+	return parsePathParam(req, paramName, strconv.ParseBool)
+}
+
+func parsePathParam[T any](req *Request, paramName string, parse func(string) (T, error)) (T, error) {
+	var zero T
+
+	value, ok := req.PathParams[paramName]
+	if !ok {
+		return zero, fmt.Errorf("%w: %s", ErrPathParamNotFound, paramName)
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		return zero, fmt.Errorf("invalid path param %s: %w", paramName, err)
+	}
+
+	return parsed, nil
+}
@@ -1,6 +1,7 @@
 package request
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -22,15 +23,38 @@ type Request struct {
 	RequestLine RequestLine
 	Headers     headers.Headers
 	Body        []byte
+	BodyReader  io.ReadCloser
+	Trailers    headers.Headers
 	PathParams  map[string]string
 	QueryParams map[string]string
 	State       int
+
+	// Query string as sent, without the '?', e.g : "name=andrew&isAdmin=false"
+	RawQuery string
+
+	// Set by the router once the path matched a route, e.g : "/users/{userId}" for /users/42.
+	// Empty if nothing matched
+	RoutePattern string
+
+	// Name of the route that matched, if it was named
+	RouteName string
+
+	// Size of the header section read so far, checked against Limits
+	headerBytes int
+	headerCount int
+
+	// Bytes of the body read so far, Body stays empty when the body is streamed
+	bodyRead int
+
+	// Bytes left to read from the current chunk of a chunked body
+	chunkRemaining int
 }
 
 func NewRequest() *Request {
 	return &Request{
 		RequestLine: RequestLine{},
 		Headers:     headers.NewHeaders(),
+		Trailers:    headers.NewHeaders(),
 		State:       ReadingRequestLine,
 		Body:        []byte{},
 	}
@@ -45,67 +69,148 @@ const (
 	ReadingRequestLine = iota
 	ReadingHeader
 	ReadingBody
+	ReadingChunkSize
+	ReadingChunkData
+	ReadingTrailers
 	Done
 )
 
 func (req *Request) GetPathParam(paramName string) string {
-	paramName = strings.ToLower(paramName)
-
 	return req.PathParams[paramName]
 }
 
+// RequestReader reads consecutive requests from the same connection. Bytes
+// read past the end of one request are kept for the next one, so pipelined
+// requests on a persistent connection are not lost.
+type RequestReader struct {
+	// If true, ReadRequest returns as soon as the headers are parsed and the body
+	// is read lazily through Request.BodyReader instead of being buffered in Request.Body
+	StreamBody bool
+
+	Limits Limits
+
+	reader   io.Reader
+	buffered []byte
+	eof      bool
+
+	// Last request returned in streaming mode, its body might not be fully read yet
+	streaming *Request
+}
+
+func NewRequestReader(reader io.Reader) *RequestReader {
+	return &RequestReader{
+		reader:   reader,
+		buffered: []byte{},
+	}
+}
+
 func RequestFromReader(reader io.Reader) (*Request, error) {
-	buffer := make([]byte, bufferSize)
-	var accumulatedBytes []byte
+	return NewRequestReader(reader).ReadRequest()
+}
+
+// ReadRequest parses the next request from the underlying reader. It returns
+// io.EOF if the reader is exhausted before any byte of a new request arrives.
+func (rr *RequestReader) ReadRequest() (*Request, error) {
+	err := rr.finishStreaming()
+	if err != nil {
+		return nil, err
+	}
+
+	if rr.eof && len(rr.buffered) == 0 {
+		return nil, io.EOF
+	}
+
 	req := NewRequest()
-	eofFlag := false
 
-	for req.State != Done {
-		bytesConsumed, newState, err := parse(accumulatedBytes, req, eofFlag)
+	for req.State != Done && !isReadingBody(req.State) {
+		err := rr.advance(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if isReadingBody(req.State) {
+		req.BodyReader = &bodyReader{rr: rr, req: req}
+		rr.streaming = req
+
+		if !rr.StreamBody {
+			err := req.ReadBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		req.BodyReader = io.NopCloser(bytes.NewReader(req.Body))
+	}
+
+	// Reading the request from the wire it's done, try to process query paramaters if possible
+	idxOfQuestionMark, _ := addQueryParams(req)
+
+	if idxOfQuestionMark != -1 {
+		req.RawQuery = req.RequestLine.RequestTarget[idxOfQuestionMark+1:]
+		req.RequestLine.RequestTarget = req.RequestLine.RequestTarget[:idxOfQuestionMark]
+	}
+
+	return req, nil
+}
+
+// advance moves req to its next parsing step, reading from the underlying reader
+// when the buffered bytes are not enough to make progress.
+func (rr *RequestReader) advance(req *Request) error {
+	buffer := make([]byte, bufferSize)
+
+	for {
+		bytesConsumed, newState, err := parse(rr.buffered, req, rr.eof, rr.Limits)
 
 		if err != nil {
-			return nil, fmt.Errorf("error parsing: %w", err)
+			return fmt.Errorf("error parsing: %w", err)
 		}
 
-		if bytesConsumed > 0 {
-			accumulatedBytes = accumulatedBytes[bytesConsumed:]
+		if bytesConsumed > 0 || newState != req.State {
+			rr.buffered = rr.buffered[bytesConsumed:]
 			req.State = newState
-			continue
+			return nil
 		}
 
-		nBytesRead, err := reader.Read(buffer)
+		if rr.eof {
+			return io.ErrUnexpectedEOF
+		}
+
+		nBytesRead, err := rr.reader.Read(buffer)
 
 		if nBytesRead > 0 {
-			accumulatedBytes = append(accumulatedBytes, buffer[:nBytesRead]...)
+			rr.buffered = append(rr.buffered, buffer[:nBytesRead]...)
 		}
 
-		if errors.Is(err, io.EOF) && eofFlag {
-			return nil, fmt.Errorf("EOF with incomplete data: %w", err)
-		}
+		if errors.Is(err, io.EOF) {
+			rr.eof = true
 
-		if errors.Is(err, io.EOF) && !eofFlag {
-			eofFlag = true
+			// Connection closed cleanly between two requests
+			if req.State == ReadingRequestLine && len(rr.buffered) == 0 {
+				return io.EOF
+			}
 			continue
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("error reading from io.Reader: %w", err)
+			return fmt.Errorf("error reading from io.Reader: %w", err)
 		}
 	}
-
-	// Reading the request from the wire it's done, try to process query paramaters if possible
-	idxOfQuestionMark, _ := addQueryParams(req)
-
-	if idxOfQuestionMark != -1 {
-		req.RequestLine.RequestTarget = req.RequestLine.RequestTarget[:idxOfQuestionMark]
-	}
-
-	return req, nil
 }
 
-func parse(bytes []byte, req *Request, eofFlag bool) (int, int, error) {
+func parse(bytes []byte, req *Request, eofFlag bool, limits Limits) (int, int, error) {
 	switch req.State {
 	case ReadingRequestLine:
+		// Ignore empty lines sent before the request line (RFC 9112, section 2.2)
+		if strings.HasPrefix(string(bytes), crlf) {
+			return len(crlf), ReadingRequestLine, nil
+		}
+
+		err := limits.checkRequestLine(bytes)
+		if err != nil {
+			return 0, ReadingRequestLine, err
+		}
+
 		requestLine, bytesConsumed, err := parseRequestLine(bytes)
 		if err != nil {
 			return 0, ReadingRequestLine, err
@@ -117,7 +222,7 @@ func parse(bytes []byte, req *Request, eofFlag bool) (int, int, error) {
 		}
 
 		if eofFlag {
-			return 0, ReadingRequestLine, errors.New("incomplete request line at EOF")
+			return 0, ReadingRequestLine, fmt.Errorf("incomplete request line: %w", io.ErrUnexpectedEOF)
 		}
 
 		return 0, ReadingRequestLine, nil
@@ -126,60 +231,246 @@ func parse(bytes []byte, req *Request, eofFlag bool) (int, int, error) {
 		bytesConsumed, doubleCrlfFlag, err := req.Headers.ParseHeader(bytes)
 
 		if err != nil {
-			return 0, ReadingHeader, err
+			return 0, ReadingHeader, fmt.Errorf("%w: %w", ErrMalformedHeader, err)
 		}
 
 		if doubleCrlfFlag {
-			if req.Headers.Get("content-length") != "" {
-				return len(crlf), ReadingBody, nil
+			nextState, err := bodyState(req.Headers, limits)
+			if err != nil {
+				return 0, ReadingHeader, err
 			}
 
-			return len(crlf), Done, nil
+			return len(crlf), nextState, nil
+		}
+
+		err = limits.checkHeaders(req, bytes, bytesConsumed)
+		if err != nil {
+			return 0, ReadingHeader, err
 		}
 
 		if bytesConsumed > 0 {
+			req.headerBytes += bytesConsumed
+			req.headerCount++
 			return bytesConsumed, ReadingHeader, nil
 		}
 
 		if eofFlag {
-			return 0, ReadingRequestLine, errors.New("incomplete header at EOF")
+			return 0, ReadingRequestLine, fmt.Errorf("incomplete header: %w", io.ErrUnexpectedEOF)
 		}
 
 		return 0, ReadingHeader, nil
 
 	case ReadingBody:
-		contentLength, err := strconv.Atoi(req.Headers.Get("content-length"))
+		contentLength, err := parseContentLength(req.Headers.Get("content-length"))
 		if err != nil {
 			return 0, ReadingBody, err
 		}
 
-		bodyPiece, bytesConsumed := parseBody(bytes)
+		if req.bodyRead == contentLength {
+			return 0, Done, nil
+		}
+
+		// Never read past content-length, the remaining bytes belong to the next request
+		bodyPiece, bytesConsumed := parseBody(bytes, contentLength-req.bodyRead)
 
 		if bytesConsumed > 0 {
 			req.Body = append(req.Body, bodyPiece...)
+			req.bodyRead += bytesConsumed
 
-			if len(req.Body) > contentLength {
-				return bytesConsumed, ReadingBody, errors.New("EOF when reading body, content-length > body length")
-			}
-
-			if len(req.Body) == contentLength {
+			if req.bodyRead == contentLength {
 				return bytesConsumed, Done, nil
 			}
 			return bytesConsumed, ReadingBody, nil
 		}
 
 		if eofFlag {
-			return 0, ReadingBody, errors.New("EOF when reading body, content-length < body length")
+			return 0, ReadingBody, fmt.Errorf("%w: EOF after %d of %d bytes", ErrBodyLengthMismatch, req.bodyRead, contentLength)
 		}
 
 		return 0, ReadingBody, nil
 
+	case ReadingChunkSize:
+		chunkSize, bytesConsumed, err := parseChunkSize(bytes)
+		if err != nil {
+			return 0, ReadingChunkSize, err
+		}
+
+		if bytesConsumed > 0 {
+			if chunkSize == 0 {
+				return bytesConsumed, ReadingTrailers, nil
+			}
+
+			err := limits.checkBody(req.bodyRead + chunkSize)
+			if err != nil {
+				return 0, ReadingChunkSize, err
+			}
+
+			req.chunkRemaining = chunkSize
+			return bytesConsumed, ReadingChunkData, nil
+		}
+
+		if eofFlag {
+			return 0, ReadingChunkSize, fmt.Errorf("incomplete chunk size: %w", io.ErrUnexpectedEOF)
+		}
+
+		return 0, ReadingChunkSize, nil
+
+	case ReadingChunkData:
+		// Chunk data is fully read, only the CRLF closing the chunk is left
+		if req.chunkRemaining == 0 {
+			if len(bytes) >= len(crlf) {
+				if string(bytes[:len(crlf)]) != crlf {
+					return 0, ReadingChunkData, fmt.Errorf("%w: chunk data not followed by CRLF", ErrMalformedBody)
+				}
+
+				return len(crlf), ReadingChunkSize, nil
+			}
+
+			if eofFlag {
+				return 0, ReadingChunkData, fmt.Errorf("incomplete chunk: %w", io.ErrUnexpectedEOF)
+			}
+
+			return 0, ReadingChunkData, nil
+		}
+
+		bodyPiece, bytesConsumed := parseBody(bytes, req.chunkRemaining)
+
+		if bytesConsumed > 0 {
+			req.Body = append(req.Body, bodyPiece...)
+			req.bodyRead += bytesConsumed
+			req.chunkRemaining -= bytesConsumed
+			return bytesConsumed, ReadingChunkData, nil
+		}
+
+		if eofFlag {
+			return 0, ReadingChunkData, fmt.Errorf("incomplete chunk: %w", io.ErrUnexpectedEOF)
+		}
+
+		return 0, ReadingChunkData, nil
+
+	case ReadingTrailers:
+		bytesConsumed, doubleCrlfFlag, err := req.Trailers.ParseHeader(bytes)
+		if err != nil {
+			return 0, ReadingTrailers, fmt.Errorf("%w: %w", ErrMalformedHeader, err)
+		}
+
+		if doubleCrlfFlag {
+			return len(crlf), Done, nil
+		}
+
+		err = limits.checkHeaders(req, bytes, bytesConsumed)
+		if err != nil {
+			return 0, ReadingTrailers, err
+		}
+
+		if bytesConsumed > 0 {
+			req.headerBytes += bytesConsumed
+			req.headerCount++
+			return bytesConsumed, ReadingTrailers, nil
+		}
+
+		if eofFlag {
+			return 0, ReadingTrailers, fmt.Errorf("incomplete trailer: %w", io.ErrUnexpectedEOF)
+		}
+
+		return 0, ReadingTrailers, nil
+
 	default:
 		return -1, -1, errors.New("unsuported state")
 	}
 }
 
-func parseBody(bytes []byte) (body []byte, bytesConsumed int) {
+// bodyState picks how the body is framed once all the headers were read (RFC 9112, section 6.3)
+func bodyState(h headers.Headers, limits Limits) (int, error) {
+	if h.Get("transfer-encoding") != "" {
+		if h.Get("content-length") != "" {
+			return 0, fmt.Errorf("%w: both transfer-encoding and content-length present", ErrMalformedBody)
+		}
+
+		// Only chunked is decoded, the body would be handed over still encoded otherwise
+		codings := strings.Split(h.Get("transfer-encoding"), ",")
+		for _, coding := range codings {
+			if !strings.EqualFold(strings.TrimSpace(coding), "chunked") {
+				return 0, fmt.Errorf("%w: %s", ErrUnsupportedTransferEncoding, h.Get("transfer-encoding"))
+			}
+		}
+
+		if len(codings) > 1 {
+			return 0, fmt.Errorf("%w: chunked applied more than once", ErrMalformedBody)
+		}
+
+		return ReadingChunkSize, nil
+	}
+
+	if h.Get("content-length") != "" {
+		contentLength, err := parseContentLength(h.Get("content-length"))
+		if err != nil {
+			return 0, err
+		}
+
+		err = limits.checkBody(contentLength)
+		if err != nil {
+			return 0, err
+		}
+
+		return ReadingBody, nil
+	}
+
+	return Done, nil
+}
+
+// parseContentLength only accepts digits, strconv.Atoi would also take a sign, e.g : "+3"
+func parseContentLength(value string) (int, error) {
+	if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: invalid content-length: %s", ErrMalformedBody, value)
+	}
+
+	contentLength, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid content-length: %w", ErrMalformedBody, err)
+	}
+
+	return contentLength, nil
+}
+
+// parseChunkSize parses a chunk size line, e.g : "1a;name=value\r\n". Chunk extensions are ignored.
+// The line, extensions included, can't be longer than maxChunkSizeLineBytes.
+func parseChunkSize(bytes []byte) (chunkSize int, bytesConsumed int, err error) {
+	// Only look for the CRLF where it may be, the rest can be chunk data
+	if len(bytes) > maxChunkSizeLineBytes+len(crlf) {
+		bytes = bytes[:maxChunkSizeLineBytes+len(crlf)]
+	}
+	str := string(bytes)
+
+	idx := strings.Index(str, crlf)
+
+	if idx == -1 {
+		if len(bytes) == maxChunkSizeLineBytes+len(crlf) {
+			return 0, 0, fmt.Errorf("%w: chunk size line longer than %d bytes", ErrMalformedBody, maxChunkSizeLineBytes)
+		}
+
+		return 0, 0, nil
+	}
+
+	sizeStr := str[:idx]
+	if extIdx := strings.IndexByte(sizeStr, ';'); extIdx != -1 {
+		sizeStr = sizeStr[:extIdx]
+	}
+	sizeStr = strings.TrimSpace(sizeStr)
+
+	size, err := strconv.ParseUint(sizeStr, 16, 31)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid chunk size: %q", ErrMalformedBody, sizeStr)
+	}
+
+	return int(size), idx + len(crlf), nil
+}
+
+func parseBody(bytes []byte, remaining int) (body []byte, bytesConsumed int) {
+	if len(bytes) > remaining {
+		bytes = bytes[:remaining]
+	}
+
 	if len(bytes) > 0 {
 		return bytes, len(bytes)
 	}
@@ -201,22 +492,27 @@ func parseRequestLine(bytes []byte) (RequestLine, int, error) {
 	requestLinesParts := strings.Split(substr, " ")
 
 	if len(requestLinesParts) != 3 {
-		return RequestLine{}, 0, errors.New("http line start doesn't have 3 parts: " + substr)
+		return RequestLine{}, 0, fmt.Errorf("%w: doesn't have 3 parts: %s", ErrMalformedRequestLine, substr)
 	}
 
 	method, requestPath, httpVersion := requestLinesParts[0], requestLinesParts[1], requestLinesParts[2]
 
 	isAlpha := regexp.MustCompile(`^[A-Za-z]+$`).MatchString
 	if !isAlpha(method) {
-		return RequestLine{}, 0, errors.New("method contains non-letters: " + method)
+		return RequestLine{}, 0, fmt.Errorf("%w: method contains non-letters: %s", ErrMalformedRequestLine, method)
 	}
 
 	if method != strings.ToUpper(method) {
-		return RequestLine{}, 0, errors.New("method contains lower letters: " + method)
+		return RequestLine{}, 0, fmt.Errorf("%w: method contains lower letters: %s", ErrMalformedRequestLine, method)
+	}
+
+	isHttpVersion := regexp.MustCompile(`^HTTP/[0-9]\.[0-9]$`).MatchString
+	if !isHttpVersion(httpVersion) {
+		return RequestLine{}, 0, fmt.Errorf("%w: invalid http version: %s", ErrMalformedRequestLine, httpVersion)
 	}
 
 	if httpVersion != "HTTP/1.1" {
-		return RequestLine{}, 0, errors.New("not supported http version: " + httpVersion)
+		return RequestLine{}, 0, fmt.Errorf("%w: %s", ErrUnsupportedVersion, httpVersion)
 	}
 
 	reqLine := RequestLine{
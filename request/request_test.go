@@ -2,6 +2,7 @@ package request
 
 import (
 	"io"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -383,3 +384,341 @@ func TestAddQueryParams(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestReader_PersistentConnection(t *testing.T) {
+	// Test: Pipelined requests, the second one must not be swallowed by the first body
+	reader := NewRequestReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"hello" +
+			"GET /coffee HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"\r\n",
+		numBytesPerRead: 7,
+	})
+
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/submit", r.RequestLine.RequestTarget)
+	assert.Equal(t, "hello", string(r.Body))
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "GET", r.RequestLine.Method)
+	assert.Equal(t, "/coffee", r.RequestLine.RequestTarget)
+
+	// Test: Connection closed between requests
+	_, err = reader.ReadRequest()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Empty body followed by another request, leading empty line is ignored
+	reader = NewRequestReader(strings.NewReader(
+		"POST /empty HTTP/1.1\r\nContent-Length: 0\r\n\r\n" +
+			"\r\nGET / HTTP/1.1\r\n\r\n"))
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Empty(t, r.Body)
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/", r.RequestLine.RequestTarget)
+
+	// Test: Connection closed in the middle of a request
+	reader = NewRequestReader(strings.NewReader("GET / HTTP/1.1\r\nHost: local"))
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestChunkedBodyParsing(t *testing.T) {
+	// Test: Chunked body with extensions and trailers
+	reader := &chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5;name=value\r\n" +
+			"hello\r\n" +
+			"7\r\n" +
+			" world!\r\n" +
+			"0\r\n" +
+			"X-Checksum: abc\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello world!", string(r.Body))
+	assert.Equal(t, "abc", r.Trailers.Get("X-Checksum"))
+
+	// Test: Chunked body followed by another request on the same connection
+	requestReader := NewRequestReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			"a\r\n0123456789\r\n0\r\n\r\n" +
+			"GET / HTTP/1.1\r\n\r\n"))
+	r, err = requestReader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(r.Body))
+	assert.Empty(t, r.Trailers)
+
+	r, err = requestReader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/", r.RequestLine.RequestTarget)
+
+	// Test: Invalid chunk size
+	_, err = RequestFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\nhello\r\n0\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Chunk data longer than chunk size
+	_, err = RequestFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nhello\r\n0\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Missing terminating chunk
+	_, err = RequestFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n"))
+	require.Error(t, err)
+
+	// Test: Both transfer-encoding and content-length
+	_, err = RequestFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n5\r\nhello\r\n0\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Unsupported transfer coding, even when chunked comes last
+	for _, codings := range []string{"gzip", "gzip, chunked", "chunked, gzip, chunked"} {
+		_, err = RequestFromReader(strings.NewReader(
+			"POST /upload HTTP/1.1\r\nTransfer-Encoding: " + codings + "\r\n\r\n5\r\nhello\r\n0\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrUnsupportedTransferEncoding, "Transfer-Encoding: %s", codings)
+	}
+
+	// Test: Chunked applied twice
+	_, err = RequestFromReader(strings.NewReader(
+		"POST /upload HTTP/1.1\r\nTransfer-Encoding: chunked, chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrMalformedBody)
+}
+
+func TestStreamingBody(t *testing.T) {
+	// Test: Body is read lazily, nothing is buffered in Body
+	reader := NewRequestReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Content-Length: 13\r\n" +
+			"\r\n" +
+			"hello world!\n" +
+			"POST /upload HTTP/1.1\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n6\r\n world\r\n0\r\nX-Checksum: abc\r\n\r\n" +
+			"POST /ignored HTTP/1.1\r\n" +
+			"Content-Length: 7\r\n" +
+			"\r\n" +
+			"ignored" +
+			"GET /last HTTP/1.1\r\n" +
+			"\r\n",
+		numBytesPerRead: 4,
+	})
+	reader.StreamBody = true
+
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Empty(t, r.Body)
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(body))
+
+	// Test: Chunked body, trailers are available once the body is fully read
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	body, err = io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+	assert.Equal(t, "abc", r.Trailers.Get("X-Checksum"))
+
+	// Test: Unread body is discarded before the next request
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/ignored", r.RequestLine.RequestTarget)
+	require.NoError(t, r.BodyReader.Close())
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/last", r.RequestLine.RequestTarget)
+	body, err = io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	// Test: BodyReader is available on buffered requests too
+	r, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nContent-Length: 2\r\n\r\nhi"))
+	require.NoError(t, err)
+	body, err = io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(body))
+}
+
+func TestRequestReader_PeekAndReadBody(t *testing.T) {
+	reader := NewRequestReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"hello",
+		numBytesPerRead: 2,
+	})
+	reader.StreamBody = true
+
+	// Test: Peek waits for data without consuming it
+	require.NoError(t, reader.Peek())
+
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Empty(t, r.Body)
+
+	// Test: Handler decides to buffer the streamed body
+	require.NoError(t, r.ReadBody())
+	assert.Equal(t, "hello", string(r.Body))
+
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	// Test: Peek on a closed connection
+	assert.ErrorIs(t, reader.Peek(), io.EOF)
+}
+
+func TestRequestLimits(t *testing.T) {
+	limits := Limits{
+		MaxRequestLineBytes: 32,
+		MaxHeaderBytes:      64,
+		MaxHeaderCount:      3,
+		MaxBodyBytes:        8,
+	}
+
+	tests := []struct {
+		name        string
+		rawRequest  string
+		expectedErr error
+	}{
+		{
+			name:        "Within limits",
+			rawRequest:  "POST /short HTTP/1.1\r\nHost: a\r\nContent-Length: 8\r\n\r\n12345678",
+			expectedErr: nil,
+		},
+		{
+			name:        "Request line too long",
+			rawRequest:  "GET /" + strings.Repeat("a", 40) + " HTTP/1.1\r\n\r\n",
+			expectedErr: ErrRequestLineTooLong,
+		},
+		{
+			name:        "Request line too long without CRLF yet",
+			rawRequest:  "GET /" + strings.Repeat("a", 40),
+			expectedErr: ErrRequestLineTooLong,
+		},
+		{
+			name:        "Too many header bytes",
+			rawRequest:  "GET / HTTP/1.1\r\nX-Long: " + strings.Repeat("a", 64) + "\r\n\r\n",
+			expectedErr: ErrHeadersTooLarge,
+		},
+		{
+			name:        "Too many header fields",
+			rawRequest:  "GET / HTTP/1.1\r\nA: 1\r\nB: 2\r\nC: 3\r\nD: 4\r\n\r\n",
+			expectedErr: ErrHeadersTooLarge,
+		},
+		{
+			name:        "Content-Length over the body limit",
+			rawRequest:  "POST / HTTP/1.1\r\nContent-Length: 9\r\n\r\n123456789",
+			expectedErr: ErrBodyTooLarge,
+		},
+		{
+			name:        "Chunked body over the body limit",
+			rawRequest:  "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\n12345\r\n5\r\n67890\r\n0\r\n\r\n",
+			expectedErr: ErrBodyTooLarge,
+		},
+		{
+			name:        "Chunk size line too long",
+			rawRequest:  "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" + strings.Repeat("f", 5000),
+			expectedErr: ErrMalformedBody,
+		},
+		{
+			name:        "Chunk extensions too long",
+			rawRequest:  "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n1;ext=" + strings.Repeat("a", 5000) + "\r\na\r\n0\r\n\r\n",
+			expectedErr: ErrMalformedBody,
+		},
+		{
+			name:        "Too many trailer fields",
+			rawRequest:  "POST / HTTP/1.1\r\nA: 1\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nB: 2\r\nC: 3\r\n\r\n",
+			expectedErr: ErrHeadersTooLarge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := NewRequestReader(&chunkReader{data: tc.rawRequest, numBytesPerRead: 5})
+			reader.Limits = limits
+
+			_, err := reader.ReadRequest()
+			if tc.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		rawRequest  string
+		expectedErr error
+	}{
+		{"Missing method", "/coffee HTTP/1.1\r\n\r\n", ErrMalformedRequestLine},
+		{"Lowercase method", "get / HTTP/1.1\r\n\r\n", ErrMalformedRequestLine},
+		{"Garbage version", "GET / HTTPS\r\n\r\n", ErrMalformedRequestLine},
+		{"Unsupported version", "GET / HTTP/1.0\r\n\r\n", ErrUnsupportedVersion},
+		{"Header without colon", "GET / HTTP/1.1\r\nHost localhost\r\n\r\n", ErrMalformedHeader},
+		{"Invalid content-length", "POST / HTTP/1.1\r\nContent-Length: abc\r\n\r\n", ErrMalformedBody},
+		{"Signed content-length", "POST / HTTP/1.1\r\nContent-Length: +3\r\n\r\nabc", ErrMalformedBody},
+		{"Negative content-length", "POST / HTTP/1.1\r\nContent-Length: -3\r\n\r\n", ErrMalformedBody},
+		{"Invalid chunk size", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", ErrMalformedBody},
+		{"Body shorter than content-length", "POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nabc", ErrBodyLengthMismatch},
+		{"Unsupported transfer-encoding", "POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n", ErrUnsupportedTransferEncoding},
+		{"Incomplete headers", "GET / HTTP/1.1\r\nHost: local", io.ErrUnexpectedEOF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := RequestFromReader(strings.NewReader(tc.rawRequest))
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestTypedPathParams(t *testing.T) {
+	req := NewRequest()
+	req.PathParams = map[string]string{"id": "42", "price": "9.5", "active": "true", "slug": "hello"}
+
+	id, err := req.PathParamInt("id")
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+
+	id64, err := req.PathParamInt64("id")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), id64)
+
+	price, err := req.PathParamFloat64("price")
+	require.NoError(t, err)
+	assert.Equal(t, 9.5, price)
+
+	active, err := req.PathParamBool("active")
+	require.NoError(t, err)
+	assert.True(t, active)
+
+	_, err = req.PathParamInt("slug")
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+
+	_, err = req.PathParamInt("missing")
+	assert.ErrorIs(t, err, ErrPathParamNotFound)
+}
@@ -0,0 +1,63 @@
+package response
+
+import (
+	"slices"
+	"strings"
+)
+
+// Group registers handlers on a Router under a common path prefix, wrapping them
+// in middleware that only runs for the group's routes, e.g : auth for /admin
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []Middleware
+}
+
+// Group returns a group of routes under prefix. The middleware run after the global ones,
+// in the order they are given.
+func (r *Router) Group(prefix string, mws ...Middleware) *Group {
+	return &Group{
+		router:     r,
+		prefix:     strings.TrimSuffix(prefix, "/"),
+		middleware: slices.Clone(mws),
+	}
+}
+
+// Group returns a nested group, its routes run the parent's middleware first
+func (g *Group) Group(prefix string, mws ...Middleware) *Group {
+	return &Group{
+		router:     g.router,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: slices.Concat(g.middleware, mws),
+	}
+}
+
+// Use adds middleware to the group, it only applies to handlers added afterwards
+func (g *Group) Use(mw Middleware) {
+	g.middleware = append(g.middleware, mw)
+}
+
+// AddHandler registers h under the group's prefix, e.g : "/users" in group "/api/v1" is "/api/v1/users".
+// Route middleware run after the group's ones.
+func (g *Group) AddHandler(method HttpMethod, path string, h Handler, mws ...Middleware) *Route {
+	return g.router.AddHandler(method, g.prefix+path, h, slices.Concat(g.middleware, mws)...)
+}
+
+// AddHandlerMethods registers h for each of methods under the group's prefix
+func (g *Group) AddHandlerMethods(methods []HttpMethod, path string, h Handler, mws ...Middleware) []*Route {
+	return g.router.AddHandlerMethods(methods, g.prefix+path, h, slices.Concat(g.middleware, mws)...)
+}
+
+// Any registers h for all the StandardMethods under the group's prefix
+func (g *Group) Any(path string, h Handler, mws ...Middleware) []*Route {
+	return g.router.Any(g.prefix+path, h, slices.Concat(g.middleware, mws)...)
+}
+
+// wrapHandler applies mws to h, the first middleware being the outermost one
+func wrapHandler(h Handler, mws []Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+
+	return h
+}
@@ -0,0 +1,128 @@
+package response
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/Ciobi0212/httpfromtcp/request"
+)
+
+// SubdomainParam is the path parameter holding the label matched by a "*.example.com" host pattern
+const SubdomainParam = "subdomain"
+
+type hostRoute struct {
+	// E.g : "api.example.com", or ".tenant.example.com" for "*.tenant.example.com"
+	pattern  string
+	wildcard bool
+	router   *Router
+}
+
+// Host returns the router serving requests for the host pattern, creating it if needed with
+// the same settings as r, NotFound and MethodNotAllowed included. Patterns are either exact, e.g : "api.example.com",
+// or start with "*." to match a single label captured as SubdomainParam, e.g : "*.tenant.example.com".
+// Exact patterns win over wildcard ones, which are tried from the longest. Requests for
+// other hosts are served by r itself, its global middleware running before the host router's.
+func (r *Router) Host(pattern string) *Router {
+	pattern = strings.ToLower(pattern)
+	wildcard := strings.HasPrefix(pattern, "*.")
+	pattern = strings.TrimPrefix(pattern, "*")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var hosts []*hostRoute
+	if current := r.hosts.Load(); current != nil {
+		hosts = *current
+	}
+
+	for _, host := range hosts {
+		if host.pattern == pattern && host.wildcard == wildcard {
+			return host.router
+		}
+	}
+
+	sub := NewRouter()
+	sub.CaseInsensitive = r.CaseInsensitive
+	sub.CleanPath = r.CleanPath
+	sub.TrailingSlash = r.TrailingSlash
+	sub.KnownMethods = slices.Clone(r.KnownMethods)
+	sub.NotFound = r.NotFound
+	sub.MethodNotAllowed = r.MethodNotAllowed
+
+	hosts = append(slices.Clone(hosts), &hostRoute{pattern: pattern, wildcard: wildcard, router: sub})
+	r.hosts.Store(&hosts)
+
+	return sub
+}
+
+// hostRouter returns the router for hostname and the captured subdomain, or nil if no host pattern matches
+func (r *Router) hostRouter(hostname string) (*Router, string) {
+	hosts := r.hosts.Load()
+	if hosts == nil {
+		return nil, ""
+	}
+
+	var best *hostRoute
+	var subdomain string
+
+	for _, host := range *hosts {
+		if !host.wildcard {
+			if host.pattern == hostname {
+				return host.router, ""
+			}
+			continue
+		}
+
+		label, ok := strings.CutSuffix(hostname, host.pattern)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			continue
+		}
+
+		if best == nil || len(host.pattern) > len(best.pattern) {
+			best, subdomain = host, label
+		}
+	}
+
+	if best == nil {
+		return nil, ""
+	}
+
+	return best.router, subdomain
+}
+
+// serveHost hands req to the router of its host, if any. It returns false if r should serve it.
+func (r *Router) serveHost(res ResponseWriter, req *request.Request) (bool, *HandlerError) {
+	// RFC 9112 requires exactly one Host header on HTTP/1.1 requests, it may be empty
+	host, ok := req.Headers["host"]
+	if req.RequestLine.HttpVersion == "1.1" && (!ok || strings.Contains(host, ",")) {
+		return true, &HandlerError{
+			StatusCode: BadRequest,
+			Message:    "missing or duplicate host header",
+		}
+	}
+
+	sub, subdomain := r.hostRouter(hostname(host))
+	if sub == nil {
+		return false, nil
+	}
+
+	if subdomain != "" {
+		params := map[string]string{SubdomainParam: subdomain}
+		if req.PathParams != nil {
+			params = mergeParams(req.PathParams, params)
+		}
+		req.PathParams = params
+	}
+
+	return true, wrapHandler(sub.serveHttp, sub.GlobalMiddleware)(res, req)
+}
+
+// hostname strips the port and trailing dot of a Host header, e.g : "API.example.com.:8080" is "api.example.com"
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
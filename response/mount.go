@@ -0,0 +1,74 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Ciobi0212/httpfromtcp/request"
+)
+
+// Name of the wildcard capturing the path under a mount point
+const mountPathParam = "mountPath"
+
+// Mount makes sub answer every request under prefix, whatever its method. Sub routes
+// on the rest of the path, e.g : "/users" for "/api/users" mounted on "/api", while the
+// request target stays untouched. Sub's global middleware run after the ones of r.
+// Routes added to r under prefix take precedence over sub.
+func (r *Router) Mount(prefix string, sub *Router) {
+	r.mount(prefix, func(res ResponseWriter, req *request.Request, path string) *HandlerError {
+		// Patterns of the sub-router's routes are reported under the prefix, e.g : "/api/users/{id}"
+		patternPrefix := strings.TrimSuffix(req.RoutePattern, "/{"+mountPathParam+"...}")
+
+		serve := func(res ResponseWriter, req *request.Request) *HandlerError {
+			return sub.servePath(res, req, path, patternPrefix)
+		}
+
+		return wrapHandler(serve, sub.GlobalMiddleware)(res, req)
+	})
+}
+
+// MountHTTP makes a standard library handler answer every request under prefix, e.g : pprof
+// or expvar. Like with http.ServeMux, the handler sees the full path of the request.
+func (r *Router) MountHTTP(prefix string, h http.Handler) {
+	handler := FromHTTPHandler(h)
+
+	r.mount(prefix, func(res ResponseWriter, req *request.Request, path string) *HandlerError {
+		return handler(res, req)
+	})
+}
+
+func (r *Router) mount(prefix string, h func(res ResponseWriter, req *request.Request, path string) *HandlerError) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	// The prefix itself, e.g : "/api", has nothing left to capture for the wildcard
+	r.setMountHandler(prefix, func(res ResponseWriter, req *request.Request) *HandlerError {
+		return h(res, req, "/")
+	})
+
+	r.setMountHandler(prefix+"/{"+mountPathParam+"...}", func(res ResponseWriter, req *request.Request) *HandlerError {
+		path := "/" + req.PathParams[mountPathParam]
+		delete(req.PathParams, mountPathParam)
+
+		return h(res, req, path)
+	})
+}
+
+// mountedPattern reports pattern under the prefix a router is mounted on. The sub-router's root
+// is reported as the prefix itself, e.g : "/api" and not "/api/", as it serves both paths.
+func mountedPattern(prefix string, pattern string) string {
+	if prefix != "" && pattern == "/" {
+		return prefix
+	}
+
+	return prefix + pattern
+}
+
+func (r *Router) setMountHandler(path string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chain := r.copyPath(path, true)
+	chain[len(chain)-1].MountHandler = h
+
+	r.root.Store(chain[0])
+}
@@ -0,0 +1,295 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Ciobi0212/httpfromtcp/headers"
+	"github.com/Ciobi0212/httpfromtcp/request"
+)
+
+// HTTPHandler serves a Router from the standard library, see ToHTTPHandler
+type HTTPHandler struct {
+	Router *Router
+
+	// Bodies are buffered in Request.Body, longer ones are answered with 413 Content Too Large.
+	// 0 means no limit
+	MaxBodyBytes int64
+}
+
+// ToHTTPHandler adapts a Router to the standard library, e.g : to run it behind http.Server
+// for TLS and HTTP/2, or in httptest. Handlers and middleware run unchanged, except that
+// ResponseWriter.Conn is nil as the connection belongs to http.Server.
+// Server.Limits don't apply there, bodies are bounded by request.DefaultMaxBodyBytes instead.
+func ToHTTPHandler(r *Router) *HTTPHandler {
+	return &HTTPHandler{
+		Router:       r,
+		MaxBodyBytes: request.DefaultMaxBodyBytes,
+	}
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, httpReq *http.Request) {
+	res := ResponseWriter{
+		Headers: headers.NewHeaders(),
+		state:   &writerState{httpW: w, keepAlive: true},
+	}
+
+	if h.MaxBodyBytes > 0 && httpReq.Body != nil {
+		httpReq.Body = http.MaxBytesReader(w, httpReq.Body, h.MaxBodyBytes)
+	}
+
+	req, err := fromHTTPRequest(httpReq)
+	if err != nil {
+		statusCode := BadRequest
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			statusCode = ContentTooLarge
+		}
+
+		h.Router.respondWithError(res, &HandlerError{
+			StatusCode: statusCode,
+			Message:    StatusText(statusCode),
+		})
+		return
+	}
+
+	h.Router.Handle(res, req)
+}
+
+// fromHTTPRequest buffers the body, as handlers expect it in Request.Body
+func fromHTTPRequest(httpReq *http.Request) (*request.Request, error) {
+	req := request.NewRequest()
+
+	req.RequestLine = request.RequestLine{
+		Method:        httpReq.Method,
+		RequestTarget: httpReq.URL.EscapedPath(),
+		HttpVersion:   fmt.Sprintf("%d.%d", httpReq.ProtoMajor, httpReq.ProtoMinor),
+	}
+
+	for key, values := range httpReq.Header {
+		for _, value := range values {
+			req.Headers.Add(key, value)
+		}
+	}
+	if httpReq.Host != "" {
+		req.Headers.Add("Host", httpReq.Host)
+	}
+
+	if httpReq.URL.RawQuery != "" {
+		req.RawQuery = httpReq.URL.RawQuery
+		req.QueryParams = make(map[string]string)
+		// The last value wins, as with the native parser
+		for key, values := range httpReq.URL.Query() {
+			req.QueryParams[key] = values[len(values)-1]
+		}
+	}
+
+	if httpReq.Body != nil {
+		body, err := io.ReadAll(httpReq.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading body: %w", err)
+		}
+		req.Body = body
+	}
+	req.BodyReader = io.NopCloser(bytes.NewReader(req.Body))
+
+	for key, values := range httpReq.Trailer {
+		for _, value := range values {
+			req.Trailers.Add(key, value)
+		}
+	}
+
+	req.State = request.Done
+
+	return req, nil
+}
+
+func writeHTTPHeaders(w http.ResponseWriter, h headers.Headers, statusCode StatusCode) error {
+	for key, value := range h {
+		// Framing of the response is up to http.Server
+		if key == "connection" || key == "transfer-encoding" {
+			continue
+		}
+
+		w.Header().Set(key, value)
+	}
+
+	w.WriteHeader(int(statusCode))
+	return nil
+}
+
+func writeHTTPBody(w http.ResponseWriter, p []byte) error {
+	_, err := w.Write(p)
+	if err != nil {
+		return fmt.Errorf("failed to write body: %w", err)
+	}
+
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+func writeHTTPTrailers(w http.ResponseWriter, trailers headers.Headers) {
+	for key, value := range trailers {
+		w.Header().Set(http.TrailerPrefix+key, value)
+	}
+}
+
+// FromHTTPHandler adapts a standard library handler, so it can be added to a Router
+func FromHTTPHandler(h http.Handler) Handler {
+	return func(w ResponseWriter, req *request.Request) *HandlerError {
+		httpW := &httpResponseWriter{
+			w:      w,
+			header: make(http.Header),
+		}
+
+		httpReq, err := toHTTPRequest(w, req)
+		if err != nil {
+			return &HandlerError{StatusCode: BadRequest, Message: err.Error()}
+		}
+
+		h.ServeHTTP(httpW, httpReq)
+		httpW.finish()
+
+		return nil
+	}
+}
+
+func toHTTPRequest(w ResponseWriter, req *request.Request) (*http.Request, error) {
+	header := make(http.Header)
+	for key, value := range req.Headers {
+		header.Set(key, value)
+	}
+
+	contentLength := int64(0)
+	if req.Headers.Get("content-length") != "" {
+		contentLength, _ = strconv.ParseInt(req.Headers.Get("content-length"), 10, 64)
+	} else if req.Headers.HasToken("transfer-encoding", "chunked") {
+		contentLength = -1
+	}
+
+	body := req.BodyReader
+	if body == nil {
+		body = http.NoBody
+	}
+
+	requestURI := req.RequestLine.RequestTarget
+	if req.RawQuery != "" {
+		requestURI += "?" + req.RawQuery
+	}
+
+	// Parsed like net/http does, so Path is unescaped and RawPath keeps the original encoding
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq := &http.Request{
+		Method:        req.RequestLine.Method,
+		URL:           u,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: contentLength,
+		Host:          req.Headers.Get("host"),
+		RequestURI:    requestURI,
+	}
+
+	if w.Conn != nil {
+		httpReq.RemoteAddr = w.Conn.RemoteAddr().String()
+	}
+
+	return httpReq, nil
+}
+
+// httpResponseWriter implements http.ResponseWriter on top of a ResponseWriter. Bodies
+// without a Content-Length are sent chunked, so the connection can be reused.
+type httpResponseWriter struct {
+	w           ResponseWriter
+	header      http.Header
+	wroteHeader bool
+	chunked     bool
+	err         error
+}
+
+func (hw *httpResponseWriter) Header() http.Header {
+	return hw.header
+}
+
+func (hw *httpResponseWriter) WriteHeader(statusCode int) {
+	if hw.wroteHeader {
+		return
+	}
+	hw.wroteHeader = true
+
+	for key, values := range hw.header {
+		for _, value := range values {
+			hw.w.Headers.Add(key, value)
+		}
+	}
+
+	hasBody := statusCode >= 200 && statusCode != int(NoContent) && statusCode != int(NotModified)
+	if hasBody && hw.w.Headers.Get("content-length") == "" && hw.w.Headers.Get("transfer-encoding") == "" {
+		hw.w.Headers.Add("Transfer-Encoding", "chunked")
+		hw.chunked = true
+	}
+
+	hw.err = hw.w.WriteHeaders(StatusCode(statusCode))
+}
+
+func (hw *httpResponseWriter) Write(p []byte) (int, error) {
+	if !hw.wroteHeader {
+		if hw.header.Get("Content-Type") == "" {
+			hw.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		hw.WriteHeader(http.StatusOK)
+	}
+
+	if hw.err != nil {
+		return 0, hw.err
+	}
+
+	// An empty chunk would end the body
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if hw.chunked {
+		hw.err = hw.w.WriteChunkedBody(p)
+	} else {
+		hw.err = hw.w.WriteBody(p)
+	}
+
+	if hw.err != nil {
+		return 0, hw.err
+	}
+
+	return len(p), nil
+}
+
+// Flush is a no-op, everything is written straight to the connection
+func (hw *httpResponseWriter) Flush() {}
+
+// finish sends the headers if the handler never wrote anything and ends a chunked body
+func (hw *httpResponseWriter) finish() {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+
+	if hw.chunked && hw.err == nil {
+		hw.err = hw.w.WriteChunkedBodyDone()
+		if hw.err == nil {
+			hw.err = hw.w.WriteTrailers(nil)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package response
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/Ciobi0212/httpfromtcp/request"
+)
+
+// DefaultNotFound answers 404 Not Found in plain text, or in JSON if the client's Accept header prefers it
+func DefaultNotFound(w ResponseWriter, req *request.Request) *HandlerError {
+	body := StatusText(NotFound)
+	contentType := "text/plain"
+
+	if prefersJSON(req.Headers.Get("Accept")) {
+		bytes, err := json.Marshal(map[string]any{
+			"status": NotFound,
+			"error":  StatusText(NotFound),
+		})
+		if err != nil {
+			return &HandlerError{StatusCode: InternalServerError, Message: err.Error()}
+		}
+
+		body, contentType = string(bytes), "application/json"
+	}
+
+	w.Headers.Add("Content-Type", contentType)
+	w.Headers.Add("Content-Length", strconv.Itoa(len(body)))
+
+	w.WriteHeaders(NotFound)
+	w.WriteBody([]byte(body))
+
+	return nil
+}
+
+// Custom404Response writes a 404 Not Found in plain text
+//
+// Deprecated: use Router.NotFound, or DefaultNotFound to follow the Accept header
+func Custom404Response(res ResponseWriter) {
+	DefaultNotFound(res, request.NewRequest())
+}
+
+// DefaultMethodNotAllowed answers 405 Method Not Allowed through the router's ErrorHandler
+func DefaultMethodNotAllowed(w ResponseWriter, req *request.Request) *HandlerError {
+	return &HandlerError{
+		StatusCode: MethodNotAllowed,
+		Message:    StatusText(MethodNotAllowed),
+	}
+}
+
+// prefersJSON reports whether accept ranks application/json above text/plain, e.g : "application/json, text/plain;q=0.5"
+func prefersJSON(accept string) bool {
+	return acceptQuality(accept, "application/json") > acceptQuality(accept, "text/plain")
+}
+
+// acceptQuality returns the q value given to mediaType by its most specific range in accept,
+// or 0 if no range matches. An empty Accept header accepts anything.
+func acceptQuality(accept string, mediaType string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+
+		var rangeSpecificity int
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case mediaType:
+			rangeSpecificity = 2
+		case mainType + "/*":
+			rangeSpecificity = 1
+		case "*/*":
+			rangeSpecificity = 0
+		default:
+			continue
+		}
+
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if parsed, err := strconv.ParseFloat(value, 64); strings.EqualFold(key, "q") && err == nil {
+				q = parsed
+			}
+		}
+
+		quality, specificity = q, rangeSpecificity
+	}
+
+	return quality
+}
@@ -0,0 +1,177 @@
+package response
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required"`
+	Schema   openAPISchema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref     string `json:"$ref,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]openAPISchema `json:"schemas"`
+}
+
+// OpenAPI returns an OpenAPI 3 JSON document skeleton of Router.Routes, filled in with the
+// routes' RouteDoc. Schemas referenced under #/components/schemas/ get a placeholder object
+// to be completed by hand.
+func (r *Router) OpenAPI(title, version string) ([]byte, error) {
+	doc := openAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: title, Version: version},
+		Paths:      make(map[string]map[string]openAPIOperation),
+		Components: openAPIComponents{Schemas: make(map[string]openAPISchema)},
+	}
+
+	for _, route := range r.Routes() {
+		path, parameters := openAPIPath(route.Pattern)
+
+		operation := openAPIOperation{
+			OperationID: route.Name,
+			Parameters:  parameters,
+			Responses:   make(map[string]openAPIResponse),
+		}
+
+		if route.Doc != nil {
+			operation.Summary = route.Doc.Summary
+			operation.Description = route.Doc.Description
+			operation.Tags = route.Doc.Tags
+
+			if route.Doc.RequestSchema != "" {
+				operation.RequestBody = &openAPIBody{
+					Required: true,
+					Content:  jsonContent(route.Doc.RequestSchema),
+				}
+				doc.Components.addPlaceholder(route.Doc.RequestSchema)
+			}
+
+			for statusCode, ref := range route.Doc.ResponseSchemas {
+				operation.Responses[strconv.Itoa(int(statusCode))] = openAPIResponse{
+					Description: StatusText(statusCode),
+					Content:     jsonContent(ref),
+				}
+				doc.Components.addPlaceholder(ref)
+			}
+		}
+
+		// OpenAPI requires at least one response
+		if len(operation.Responses) == 0 {
+			operation.Responses["default"] = openAPIResponse{Description: "Default response"}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(string(route.Method))] = operation
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// openAPIPath turns a route pattern into an OpenAPI path template and its parameters,
+// e.g : "/users/{id:int}" is "/users/{id}" with an integer "id"
+func openAPIPath(pattern string) (string, []openAPIParameter) {
+	segments := strings.Split(pattern, "/")
+	parameters := []openAPIParameter{}
+
+	for i, segment := range segments {
+		var name string
+		schema := openAPISchema{Type: "string"}
+
+		if wildcardName, ok := isWildcard(segment); ok {
+			name = wildcardName
+		} else if isPathParam(segment) {
+			var constraint string
+			name, constraint = parsePathParam(segment)
+
+			switch constraint {
+			case "":
+			case "int":
+				schema = openAPISchema{Type: "integer"}
+			case "uuid":
+				schema.Format = "uuid"
+			default:
+				schema.Pattern = "^(?:" + constraint + ")$"
+			}
+		} else {
+			continue
+		}
+
+		segments[i] = "{" + name + "}"
+		parameters = append(parameters, openAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   schema,
+		})
+	}
+
+	return strings.Join(segments, "/"), parameters
+}
+
+func jsonContent(ref string) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{
+		"application/json": {Schema: openAPISchema{Ref: ref}},
+	}
+}
+
+func (c openAPIComponents) addPlaceholder(ref string) {
+	name, ok := strings.CutPrefix(ref, schemaRefPrefix)
+	if !ok {
+		return
+	}
+
+	if _, exists := c.Schemas[name]; !exists {
+		c.Schemas[name] = openAPISchema{Type: "object"}
+	}
+}
@@ -0,0 +1,47 @@
+package response
+
+import (
+	"errors"
+	"net"
+
+	"github.com/Ciobi0212/httpfromtcp/request"
+)
+
+// Status code answered for each error the request package returns when a request can't be parsed
+var readErrorStatusCodes = []struct {
+	err        error
+	statusCode StatusCode
+}{
+	{request.ErrMalformedRequestLine, BadRequest},
+	{request.ErrUnsupportedVersion, HTTPVersionNotSupported},
+	{request.ErrMalformedHeader, BadRequest},
+	{request.ErrMalformedBody, BadRequest},
+	{request.ErrBodyLengthMismatch, BadRequest},
+	{request.ErrUnsupportedTransferEncoding, NotImplemented},
+	{request.ErrRequestLineTooLong, URITooLong},
+	{request.ErrHeadersTooLarge, RequestHeaderFieldsTooLarge},
+	{request.ErrBodyTooLarge, ContentTooLarge},
+}
+
+// NewReadError maps an error returned while reading a request to the error sent back
+// to the client. It returns nil if there is nobody left to answer, e.g. the connection was closed.
+func NewReadError(err error) *HandlerError {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &HandlerError{
+			StatusCode: RequestTimeout,
+			Message:    "request timeout",
+		}
+	}
+
+	for _, readErr := range readErrorStatusCodes {
+		if errors.Is(err, readErr.err) {
+			return &HandlerError{
+				StatusCode: readErr.statusCode,
+				Message:    readErr.err.Error(),
+			}
+		}
+	}
+
+	return nil
+}
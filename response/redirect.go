@@ -0,0 +1,104 @@
+package response
+
+import (
+	"strings"
+
+	"github.com/Ciobi0212/httpfromtcp/request"
+)
+
+// PathPolicy tells the router what to do with a path that isn't canonical
+type PathPolicy int
+
+const (
+	// The path is routed as sent, e.g : /users/ doesn't match /users
+	PathPolicyNone PathPolicy = iota
+
+	// The client is redirected to the canonical path, with 301 for GET and HEAD
+	// and 308 for the other methods so they are replayed with their body
+	PathPolicyRedirect
+
+	// The canonical path is routed instead, and replaces the request target
+	PathPolicyMatch
+)
+
+// canonicalPath returns the path req should be routed to and whether the client should be
+// redirected there instead, according to the CleanPath and TrailingSlash policies
+func (r *Router) canonicalPath(req *request.Request) (string, bool) {
+	path := req.RequestLine.RequestTarget
+
+	// e.g : "*" for OPTIONS
+	if !strings.HasPrefix(path, "/") {
+		return path, false
+	}
+
+	canonical := path
+	redirect := false
+
+	if r.CleanPath != PathPolicyNone {
+		canonical = cleanPath(path)
+		redirect = canonical != path && r.CleanPath == PathPolicyRedirect
+	}
+
+	if r.TrailingSlash != PathPolicyNone && canonical != "/" {
+		if node, _ := r.findNode(canonical); node == nil {
+			toggled := canonical + "/"
+			if strings.HasSuffix(canonical, "/") {
+				toggled = strings.TrimSuffix(canonical, "/")
+			}
+
+			if node, _ := r.findNode(toggled); node != nil {
+				canonical = toggled
+				redirect = redirect || r.TrailingSlash == PathPolicyRedirect
+			}
+		}
+	}
+
+	return canonical, redirect
+}
+
+func redirectTo(res ResponseWriter, req *request.Request, path string) {
+	statusCode := PermanentRedirect
+	if method := HttpMethod(req.RequestLine.Method); method == GET || method == HEAD {
+		statusCode = MovedPermanently
+	}
+
+	// "//evil.com" or "/\\evil.com" would send browsers to another host
+	location := "/" + strings.TrimLeft(path, "/\\")
+	if req.RawQuery != "" {
+		location += "?" + req.RawQuery
+	}
+
+	res.Headers.Add("Location", location)
+	res.Headers.Add("Content-Length", "0")
+	res.WriteHeaders(statusCode)
+}
+
+// cleanPath collapses duplicate slashes and removes "." and ".." segments as in RFC 3986
+// section 5.2.4, so the path can't climb above the root, e.g : //users/../admin/. is /admin/.
+// Percent-encoded dots count as dots.
+func cleanPath(path string) string {
+	segments := strings.Split(path[1:], "/")
+	cleaned := make([]string, 0, len(segments))
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		switch strings.ReplaceAll(strings.ReplaceAll(segment, "%2e", "."), "%2E", ".") {
+		case "", ".":
+			if last {
+				cleaned = append(cleaned, "")
+			}
+		case "..":
+			if len(cleaned) > 0 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+			if last {
+				cleaned = append(cleaned, "")
+			}
+		default:
+			cleaned = append(cleaned, segment)
+		}
+	}
+
+	return "/" + strings.Join(cleaned, "/")
+}
@@ -0,0 +1,31 @@
+package response
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteStatusLine(t *testing.T) {
+	tests := []struct {
+		statusCode         StatusCode
+		expectedStatusLine string
+	}{
+		{Ok, "HTTP/1.1 200 OK\r\n"},
+		{MovedPermanently, "HTTP/1.1 301 Moved Permanently\r\n"},
+		{NotFound, "HTTP/1.1 404 Not Found\r\n"},
+		{MethodNotAllowed, "HTTP/1.1 405 Method Not Allowed\r\n"},
+		{TooManyRequests, "HTTP/1.1 429 Too Many Requests\r\n"},
+		{ServiceUnavailable, "HTTP/1.1 503 Service Unavailable\r\n"},
+		{StatusCode(299), "HTTP/1.1 299 \r\n"}, // Unknown code, empty reason phrase
+	}
+
+	for _, tc := range tests {
+		raw := captureResponse(t, func(res ResponseWriter) {
+			res.writeStatusLine(tc.statusCode)
+		})
+		assert.Equal(t, tc.expectedStatusLine, raw)
+	}
+
+	assert.Empty(t, StatusText(StatusCode(999)))
+}
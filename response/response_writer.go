@@ -3,6 +3,7 @@ package response
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"strconv"
 
 	"github.com/Ciobi0212/httpfromtcp/headers"
@@ -11,27 +12,112 @@ import (
 type ResponseWriter struct {
 	Conn    net.Conn
 	Headers headers.Headers
+
+	// Shared by every copy of the writer handed to middleware and handlers,
+	// so the server can tell what was written once the handler returns
+	state *writerState
 }
 
-func (w *ResponseWriter) writeStatusLine(statusCode StatusCode) error {
-	switch statusCode {
-	case Ok:
-		_, err := w.Conn.Write([]byte("HTTP/1.1 200 OK" + crlf))
-		return err
-	case BadRequest:
-		_, err := w.Conn.Write([]byte("HTTP/1.1 400 Bad Request" + crlf))
-		return err
-	case InternalServerError:
-		_, err := w.Conn.Write([]byte("HTTP/1.1 500 Internal Server Error" + crlf))
-		return err
-	default:
-		_, err := w.Conn.Write([]byte("HTTP/1.1 " + strconv.Itoa(int(statusCode)) + crlf))
-		return err
+type writerState struct {
+	// Set when running behind the standard library server, which then owns the connection
+	httpW http.ResponseWriter
+
+	keepAlive       bool
+	suppressBody    bool
+	headersWritten  bool
+	chunked         bool
+	chunkedDone     bool
+	trailersWritten bool
+}
 
+// NewResponseWriter returns a writer for a single response on conn. If keepAlive
+// is false the response announces "Connection: close".
+func NewResponseWriter(conn net.Conn, keepAlive bool) ResponseWriter {
+	return ResponseWriter{
+		Conn:    conn,
+		Headers: headers.NewHeaders(),
+		state:   &writerState{keepAlive: keepAlive},
 	}
 }
 
+// KeepAlive reports whether the connection can be reused once the handler returned:
+// the response was fully framed and nobody asked for the connection to be closed.
+func (w *ResponseWriter) KeepAlive() bool {
+	if w.state == nil || !w.state.headersWritten {
+		return false
+	}
+
+	if w.state.chunked && !w.state.chunkedDone {
+		return false
+	}
+
+	return !w.Headers.HasToken("connection", "close")
+}
+
+// Finish completes a chunked body whose trailers were never written, so the next
+// response on a persistent connection doesn't start in the middle of the previous one.
+func (w *ResponseWriter) Finish() error {
+	if w.state == nil || !w.state.chunkedDone || w.state.trailersWritten {
+		return nil
+	}
+
+	return w.WriteTrailers(headers.NewHeaders())
+}
+
+// suppressBody drops everything written after the headers, e.g : to answer HEAD requests.
+// Headers, Content-Length included, are sent as the handler set them.
+func (w *ResponseWriter) suppressBody() {
+	if w.state == nil {
+		w.state = &writerState{}
+	}
+
+	w.state.suppressBody = true
+}
+
+func (w *ResponseWriter) httpWriter() http.ResponseWriter {
+	if w.state == nil {
+		return nil
+	}
+
+	return w.state.httpW
+}
+
+func (w *ResponseWriter) bodySuppressed() bool {
+	return w.state != nil && w.state.suppressBody
+}
+
+// Without content-length or chunked encoding the client can only find the end of
+// the body when the connection is closed
+func hasBodyFraming(statusCode StatusCode, h headers.Headers) bool {
+	if statusCode < 200 || statusCode == NoContent || statusCode == NotModified {
+		return true
+	}
+
+	return h.Get("content-length") != "" || h.HasToken("transfer-encoding", "chunked")
+}
+
+func (w *ResponseWriter) writeStatusLine(statusCode StatusCode) error {
+	// Reason phrase can be empty for unknown codes, but the space before it is still required
+	statusLine := "HTTP/1.1 " + strconv.Itoa(int(statusCode)) + " " + StatusText(statusCode) + crlf
+
+	_, err := w.Conn.Write([]byte(statusLine))
+	return err
+}
+
 func (w *ResponseWriter) WriteHeaders(statusCode StatusCode) error {
+	if hw := w.httpWriter(); hw != nil {
+		w.state.headersWritten = true
+		return writeHTTPHeaders(hw, w.Headers, statusCode)
+	}
+
+	if w.state != nil {
+		framed := w.state.suppressBody || hasBodyFraming(statusCode, w.Headers)
+		if (!w.state.keepAlive || !framed) && !w.Headers.HasToken("connection", "close") {
+			w.Headers.Add("connection", "close")
+		}
+		w.state.headersWritten = true
+	}
+
 	err := w.writeStatusLine(statusCode)
 	if err != nil {
 		return fmt.Errorf("failed to write status line when sending headers: %w", err)
@@ -55,6 +141,14 @@ func (w *ResponseWriter) WriteHeaders(statusCode StatusCode) error {
 }
 
 func (w *ResponseWriter) WriteBody(p []byte) error {
+	if w.bodySuppressed() {
+		return nil
+	}
+
+	if hw := w.httpWriter(); hw != nil {
+		return writeHTTPBody(hw, p)
+	}
+
 	_, err := w.Conn.Write(p)
 	if err != nil {
 		return fmt.Errorf("failed to write body: %w", err)
@@ -82,6 +176,19 @@ func (w *ResponseWriter) RespondWithHandleError(e *HandlerError) error {
 }
 
 func (w *ResponseWriter) WriteChunkedBody(p []byte) error {
+	if w.bodySuppressed() {
+		return nil
+	}
+
+	if w.state != nil {
+		w.state.chunked = true
+	}
+
+	// The standard library server does the chunked encoding itself
+	if hw := w.httpWriter(); hw != nil {
+		return writeHTTPBody(hw, p)
+	}
+
 	lengthInHex := fmt.Sprintf("%02x", len(p))
 
 	_, err := w.Conn.Write([]byte(lengthInHex + crlf))
@@ -103,6 +210,19 @@ func (w *ResponseWriter) WriteChunkedBody(p []byte) error {
 }
 
 func (w *ResponseWriter) WriteChunkedBodyDone() error {
+	if w.bodySuppressed() {
+		return nil
+	}
+
+	if w.state != nil {
+		w.state.chunked = true
+		w.state.chunkedDone = true
+	}
+
+	if w.httpWriter() != nil {
+		return nil
+	}
+
 	str := "0" + crlf
 
 	_, err := w.Conn.Write([]byte(str))
@@ -114,6 +234,18 @@ func (w *ResponseWriter) WriteChunkedBodyDone() error {
 }
 
 func (w *ResponseWriter) WriteTrailers(trailers headers.Headers) error {
+	if w.bodySuppressed() {
+		return nil
+	}
+
+	if w.state != nil {
+		w.state.trailersWritten = true
+	}
+
+	if hw := w.httpWriter(); hw != nil {
+		writeHTTPTrailers(hw, trailers)
+		return nil
+	}
 
 	for key, value := range trailers {
 		str := fmt.Sprintf("%s: %s%s", key, value, crlf)
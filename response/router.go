@@ -3,8 +3,13 @@ package response
 import (
 	"fmt"
 	"log"
-	"strconv"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Ciobi0212/httpfromtcp/request"
 )
@@ -17,8 +22,33 @@ const (
 	POST    HttpMethod = "POST"
 	DELETE  HttpMethod = "DELETE"
 	OPTIONS HttpMethod = "OPTIONS"
+	HEAD    HttpMethod = "HEAD"
+	PATCH   HttpMethod = "PATCH"
+	CONNECT HttpMethod = "CONNECT"
+	TRACE   HttpMethod = "TRACE"
 )
 
+// WebDAV methods, RFC 4918
+const (
+	PROPFIND  HttpMethod = "PROPFIND"
+	PROPPATCH HttpMethod = "PROPPATCH"
+	MKCOL     HttpMethod = "MKCOL"
+	COPY      HttpMethod = "COPY"
+	MOVE      HttpMethod = "MOVE"
+	LOCK      HttpMethod = "LOCK"
+	UNLOCK    HttpMethod = "UNLOCK"
+)
+
+// StandardMethods returns the methods of RFC 9110 and PATCH, e.g : the ones registered by Router.Any
+func StandardMethods() []HttpMethod {
+	return []HttpMethod{GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE}
+}
+
+// DefaultKnownMethods returns the standard and WebDAV methods, the default of Router.KnownMethods
+func DefaultKnownMethods() []HttpMethod {
+	return append(StandardMethods(), PROPFIND, PROPPATCH, MKCOL, COPY, MOVE, LOCK, UNLOCK)
+}
+
 type RouterNode struct {
 	// E.g : "/{userId}, /{apiKey}"
 	IsParamater bool
@@ -26,17 +56,45 @@ type RouterNode struct {
 	// E.g : "/users, /api, /auth"
 	Segment string
 
+	// Path of the node as first added, e.g : "/users/{userId}"
+	Pattern string
+
 	// E.g : "userId, apiKey"
 	ParamaterName string
 
 	// E.g : "/users -> /profile (/user/profile)"
 	Children map[string]*RouterNode
 
-	// E.g : "/users -> /{userId} (/user/{userId})"
-	ParamChildren *RouterNode
+	// E.g : "/users -> /{userId:int}, /{userName} (/user/{userId:int}, /user/{userName})".
+	// Constrained parameters come first, in the order they were added
+	ParamChildren []*RouterNode
 
-	// Callbacks for endpoint
+	// E.g : "int, uuid, [a-z-]+", empty if the parameter matches any value
+	Constraint string
+
+	// Full match of Constraint, nil if there is none
+	constraintRegexp *regexp.Regexp
+
+	// E.g : "/{filePath...}, /*filePath", captures the rest of the path, slashes included
+	IsWildcard bool
+
+	// E.g : "/static -> /{filePath...} (/static/{filePath...})"
+	WildcardChild *RouterNode
+
+	// Callbacks for endpoint, already wrapped in their route middleware
 	Handlers map[HttpMethod]Handler
+
+	// Middleware given when adding each handler, outermost first
+	Middleware map[HttpMethod][]Middleware
+
+	// Route names given with Route.Name, e.g : "user-settings"
+	Names map[HttpMethod]string
+
+	// Documentation given with Route.Doc, e.g : for the OpenAPI export
+	Docs map[HttpMethod]*RouteDoc
+
+	// Answers every method without its own handler, e.g : a mounted sub-router
+	MountHandler Handler
 }
 
 func NewRouterNode(segment string) *RouterNode {
@@ -47,6 +105,9 @@ func NewRouterNode(segment string) *RouterNode {
 		Children:      make(map[string]*RouterNode),
 		ParamChildren: nil,
 		Handlers:      make(map[HttpMethod]Handler),
+		Middleware:    make(map[HttpMethod][]Middleware),
+		Names:         make(map[HttpMethod]string),
+		Docs:          make(map[HttpMethod]*RouteDoc),
 	}
 }
 
@@ -58,142 +119,664 @@ func NewRouterParamNode(paramName string) *RouterNode {
 		Children:      make(map[string]*RouterNode),
 		ParamChildren: nil,
 		Handlers:      make(map[HttpMethod]Handler),
+		Middleware:    make(map[HttpMethod][]Middleware),
+		Names:         make(map[HttpMethod]string),
+		Docs:          make(map[HttpMethod]*RouteDoc),
+	}
+}
+
+func NewRouterWildcardNode(paramName string) *RouterNode {
+	return &RouterNode{
+		IsWildcard:    true,
+		Segment:       "",
+		ParamaterName: paramName,
+		Children:      make(map[string]*RouterNode),
+		ParamChildren: nil,
+		Handlers:      make(map[HttpMethod]Handler),
+		Middleware:    make(map[HttpMethod][]Middleware),
+		Names:         make(map[HttpMethod]string),
+		Docs:          make(map[HttpMethod]*RouteDoc),
 	}
 }
 
+// Router is safe to use concurrently: routes can be added and removed while requests are
+// served, e.g : to turn endpoints on and off at runtime. Settings and global middleware
+// must be set before serving.
 type Router struct {
-	Root             *RouterNode
+	// Tree being served. Changes are made on copies of the nodes involved, then
+	// the new tree replaces the old one, so it is never modified while being read
+	root atomic.Pointer[RouterNode]
+
+	// Serializes changes of the tree and of the named routes
+	mu sync.Mutex
+
 	GlobalMiddleware []Middleware
+
+	// If true, static segments match regardless of case, e.g : /Users matches /users.
+	// Path parameter values are always kept as sent. Must be set before adding handlers
+	CaseInsensitive bool
+
+	// Duplicate slashes and "." or ".." segments, e.g : //users/../admin for /admin
+	CleanPath PathPolicy
+
+	// Paths only differing from a route by a trailing slash, e.g : /users/ for /users
+	TrailingSlash PathPolicy
+
+	// Methods the server implements, others are answered with 501 Not Implemented. Custom
+	// methods need to be added to be routed. If nil, any method is routed
+	KnownMethods []HttpMethod
+
+	// Writes the response for handler errors and requests that can't be read, e.g. to
+	// answer with JSON instead of plain text. Defaults to ResponseWriter.RespondWithHandleError
+	ErrorHandler func(w ResponseWriter, e *HandlerError)
+
+	// Answers requests whose path matches no route, after the global middleware ran,
+	// e.g : to serve an HTML page. Defaults to DefaultNotFound
+	NotFound Handler
+
+	// Answers requests whose path matches a route but not for their method, the Allow
+	// header being already set. Defaults to DefaultMethodNotAllowed
+	MethodNotAllowed Handler
+
+	// Routes by name, see Route.Name and Router.URL
+	namedRoutes map[string]*Route
+
+	// Routers of the host patterns, see Router.Host. Replaced as a whole, like the tree
+	hosts atomic.Pointer[[]*hostRoute]
 }
 
 func NewRouter() *Router {
-	return &Router{
-		// Root doesn't represent any valid endpoint, just used to serve as root of the tree
-		Root:             NewRouterNode(""),
+	r := &Router{
 		GlobalMiddleware: []Middleware{},
+		KnownMethods:     DefaultKnownMethods(),
+		namedRoutes:      make(map[string]*Route),
 	}
+
+	// Root doesn't represent any valid endpoint, just used to serve as root of the tree
+	r.root.Store(NewRouterNode(""))
+
+	return r
+}
+
+// Root returns the tree currently served. It must not be modified, routes are
+// changed through the Router methods.
+func (r *Router) Root() *RouterNode {
+	return r.root.Load()
 }
 
 func isPathParam(segment string) bool {
 	return len(segment) > 2 && (segment[0] == '{' && segment[len(segment)-1] == '}')
 }
 
+// Built-in parameter types, any other constraint is a regular expression
+var paramTypes = map[string]string{
+	"int":  `-?[0-9]+`,
+	"uuid": `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// parsePathParam splits "{name}" or "{name:constraint}", e.g : "{id:int}", "{slug:[a-z-]+}"
+func parsePathParam(segment string) (string, string) {
+	name, constraint, _ := strings.Cut(segment[1:len(segment)-1], ":")
+	return name, constraint
+}
+
+// compileConstraint returns a regexp matching whole segments, nil if there is no constraint
+func compileConstraint(constraint string) (*regexp.Regexp, error) {
+	if constraint == "" {
+		return nil, nil
+	}
+
+	expr, ok := paramTypes[constraint]
+	if !ok {
+		expr = constraint
+	}
+
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// isWildcard accepts both "{name...}" and "*name", returning the name of the parameter
+func isWildcard(segment string) (string, bool) {
+	if isPathParam(segment) && strings.HasSuffix(segment, "...}") && len(segment) > len("{...}") {
+		return segment[1 : len(segment)-len("...}")], true
+	}
+
+	if len(segment) > 1 && segment[0] == '*' {
+		return segment[1:], true
+	}
+
+	return "", false
+}
+
+// segmentKey is the key of a static segment in RouterNode.Children
+func (r *Router) segmentKey(segment string) string {
+	if r.CaseInsensitive {
+		return strings.ToLower(segment)
+	}
+
+	return segment
+}
+
 func (r *Router) Use(mw Middleware) {
 	r.GlobalMiddleware = append(r.GlobalMiddleware, mw)
 }
 
-func (r *Router) AddHandler(method HttpMethod, path string, h Handler) {
-	path = strings.ToLower(path)
+// AddHandler registers h for method and path. Route middleware are composed once here
+// and run after the global ones, e.g : rate limiting a single endpoint.
+// The returned Route can be named to build its URL later.
+// Custom methods must be added to KnownMethods first, the route would never be reached otherwise.
+func (r *Router) AddHandler(method HttpMethod, path string, h Handler, mws ...Middleware) *Route {
+	if r.KnownMethods != nil && !slices.Contains(r.KnownMethods, method) {
+		log.Panicf("routing error when adding handler for path: %s, method %s is not in KnownMethods", path, method)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chain := r.copyPath(path, true)
+	node := chain[len(chain)-1]
+
+	node.Handlers[method] = wrapHandler(h, mws)
+	node.Middleware[method] = slices.Clone(mws)
+
+	r.root.Store(chain[0])
+
+	return &Route{
+		Method:  method,
+		Pattern: path,
+		router:  r,
+	}
+}
+
+// AddHandlerMethods registers h for each of methods, e.g : AddHandlerMethods([]HttpMethod{PUT, PATCH}, "/users/{id}", h)
+func (r *Router) AddHandlerMethods(methods []HttpMethod, path string, h Handler, mws ...Middleware) []*Route {
+	routes := make([]*Route, 0, len(methods))
+	for _, method := range methods {
+		routes = append(routes, r.AddHandler(method, path, h, mws...))
+	}
+
+	return routes
+}
 
+// Any registers h for all the StandardMethods, taking over the automatic OPTIONS responses
+func (r *Router) Any(path string, h Handler, mws ...Middleware) []*Route {
+	return r.AddHandlerMethods(StandardMethods(), path, h, mws...)
+}
+
+// RemoveHandler removes the handler added for method and path, e.g : to turn an endpoint off
+// at runtime. The route's name is freed and nodes left without routes are pruned.
+// It returns false if there was no such handler.
+func (r *Router) RemoveHandler(method HttpMethod, path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chain := r.copyPath(path, false)
+	if chain == nil {
+		return false
+	}
+
+	node := chain[len(chain)-1]
+	if _, ok := node.Handlers[method]; !ok {
+		return false
+	}
+
+	delete(node.Handlers, method)
+	delete(node.Middleware, method)
+	delete(node.Docs, method)
+
+	if name, ok := node.Names[method]; ok {
+		delete(node.Names, method)
+		delete(r.namedRoutes, name)
+	}
+
+	for i := len(chain) - 1; i > 0 && chain[i].isEmpty(); i-- {
+		chain[i-1].removeChild(chain[i])
+	}
+
+	r.root.Store(chain[0])
+
+	return true
+}
+
+// copyPath returns copies of the nodes leading to path, from the root, creating the missing ones
+// if create is true. Once modified, the new tree is served by storing chain[0] in r.root.
+// It returns nil if path has no node and create is false. r.mu must be held.
+func (r *Router) copyPath(path string, create bool) []*RouterNode {
 	segments := strings.Split(path, "/")
 	segments[0] = "/"
 
+	curNode := NewRouterNode("")
+	if root := r.root.Load(); root != nil {
+		curNode = root.clone()
+	}
+
+	chain := make([]*RouterNode, 0, len(segments)+1)
+	chain = append(chain, curNode)
+
 	// Start traversing the tree, only adding nodes if they don't exist
 	// (Note: A node can exist, but can have a nil handler. e.g: adding endpoint /users/{userId} without already having /users endpoint)
-	curNode := r.Root
-	for _, segment := range segments {
-		if isPathParam(segment) {
-			cleanParamName := segment[1 : len(segment)-1]
-			if curNode.ParamChildren != nil {
-				if curNode.ParamChildren.ParamaterName != cleanParamName {
-					log.Panicf("routing conflict when adding handler for path: %s, conflict with paramater path: %s != %s", path, segment, cleanParamName)
+	for i, segment := range segments {
+		var child *RouterNode
+
+		if wildcardName, ok := isWildcard(segment); ok {
+			if i != len(segments)-1 {
+				if !create {
+					return nil
+				}
+				log.Panicf("routing error when adding handler for path: %s, wildcard %s must be the last segment", path, segment)
+			}
+
+			if curNode.WildcardChild != nil {
+				if curNode.WildcardChild.ParamaterName != wildcardName {
+					if !create {
+						return nil
+					}
+					log.Panicf("routing conflict when adding handler for path: %s, conflict with wildcard path: %s != %s", path, curNode.WildcardChild.ParamaterName, wildcardName)
 				}
-				curNode = curNode.ParamChildren
+				child = curNode.WildcardChild.clone()
 			} else {
-				newNode := NewRouterParamNode(cleanParamName)
-				curNode.ParamChildren = newNode
-				curNode = newNode
+				if !create {
+					return nil
+				}
+				child = NewRouterWildcardNode(wildcardName)
 			}
-			continue
-		}
 
-		child, ok := curNode.Children[segment]
+			curNode.WildcardChild = child
+		} else if isPathParam(segment) {
+			paramName, constraint := parsePathParam(segment)
+			existing := curNode.paramChild(constraint)
+			if existing != nil {
+				if existing.ParamaterName != paramName {
+					if !create {
+						return nil
+					}
+					log.Panicf("routing conflict when adding handler for path: %s, conflict with paramater path: %s != %s", path, existing.ParamaterName, paramName)
+				}
+
+				child = existing.clone()
+				curNode.ParamChildren[slices.Index(curNode.ParamChildren, existing)] = child
+			} else {
+				if !create {
+					return nil
+				}
+
+				re, err := compileConstraint(constraint)
+				if err != nil {
+					log.Panicf("routing error when adding handler for path: %s, invalid constraint %s: %v", path, constraint, err)
+				}
 
-		if !ok {
-			newNode := NewRouterNode(segment)
-			curNode.Children[segment] = newNode
-			curNode = newNode
+				child = NewRouterParamNode(paramName)
+				child.Constraint = constraint
+				child.constraintRegexp = re
+				curNode.addParamChild(child)
+			}
 		} else {
-			curNode = child
+			existing, ok := curNode.Children[r.segmentKey(segment)]
+			if ok {
+				child = existing.clone()
+			} else {
+				if !create {
+					return nil
+				}
+				child = NewRouterNode(segment)
+			}
+
+			curNode.Children[r.segmentKey(segment)] = child
 		}
+
+		if child.Pattern == "" {
+			child.Pattern = "/" + strings.Join(segments[1:i+1], "/")
+		}
+
+		chain = append(chain, child)
+		curNode = child
+	}
+
+	return chain
+}
+
+// clone returns a copy of n whose fields can be changed without affecting n, children are shared
+func (n *RouterNode) clone() *RouterNode {
+	c := *n
+	c.Children = maps.Clone(n.Children)
+	c.ParamChildren = slices.Clone(n.ParamChildren)
+	c.Handlers = maps.Clone(n.Handlers)
+	c.Middleware = maps.Clone(n.Middleware)
+	c.Names = maps.Clone(n.Names)
+	c.Docs = maps.Clone(n.Docs)
+
+	return &c
+}
+
+// isEmpty reports whether n can be pruned, having neither routes nor children
+func (n *RouterNode) isEmpty() bool {
+	return !n.isEndpoint() && len(n.Children) == 0 && len(n.ParamChildren) == 0 && n.WildcardChild == nil
+}
+
+func (n *RouterNode) removeChild(child *RouterNode) {
+	if n.WildcardChild == child {
+		n.WildcardChild = nil
 	}
 
-	curNode.Handlers[method] = h
+	n.ParamChildren = slices.DeleteFunc(n.ParamChildren, func(c *RouterNode) bool {
+		return c == child
+	})
+
+	maps.DeleteFunc(n.Children, func(_ string, c *RouterNode) bool {
+		return c == child
+	})
 }
 
 func (r *Router) GetHandlerAndPathParamsForPath(method HttpMethod, path string) (Handler, map[string]string, bool) {
+	node, pathParams := r.matchPath(path, func(n *RouterNode) bool {
+		_, ok := n.Handlers[method]
+		return ok
+	})
+	if node == nil {
+		return nil, nil, false
+	}
+
+	return node.Handlers[method], pathParams, true
+}
+
+// findNode returns the node matching path, whatever methods it has handlers for,
+// or nil if no route has this path.
+// Static segments are preferred to parameters, which are preferred to wildcards. If the
+// path leads nowhere, the deepest wildcard met along the way catches it.
+func (r *Router) findNode(path string) (*RouterNode, map[string]string) {
+	return r.matchPath(path, func(n *RouterNode) bool { return true })
+}
+
+// findNodeForMethod is findNode skipping the endpoints that don't serve method, e.g : GET /static/upload
+// is caught by GET /static/{filePath...} even if POST /static/upload exists
+func (r *Router) findNodeForMethod(path string, method HttpMethod) (*RouterNode, map[string]string) {
+	return r.matchPath(path, func(n *RouterNode) bool { return n.serves(method) })
+}
+
+// matchingNodes returns every endpoint matching path, in order of preference
+func (r *Router) matchingNodes(path string) []*RouterNode {
+	var nodes []*RouterNode
+	r.matchPath(path, func(n *RouterNode) bool {
+		nodes = append(nodes, n)
+		return false
+	})
+
+	return nodes
+}
+
+// matchPath returns the first endpoint matching path that accept returns true for, and its parameters
+func (r *Router) matchPath(path string, accept func(n *RouterNode) bool) (*RouterNode, map[string]string) {
 	pathParams := make(map[string]string)
-	path = strings.ToLower(path)
 
 	segments := strings.Split(path, "/")
 	segments[0] = "/"
 
-	curNode := r.Root
-	for _, segment := range segments {
-		child, ok := curNode.Children[segment]
+	root := r.root.Load()
+	if root == nil {
+		return nil, nil
+	}
 
-		if ok {
-			curNode = child
-		} else if curNode.ParamChildren != nil && !isPathParam(segment) {
-			curNode = curNode.ParamChildren
-			pathParams[curNode.ParamaterName] = segment
-		} else {
-			return nil, nil, false
+	node := r.matchSegments(root, segments, pathParams, accept)
+	if node == nil {
+		return nil, nil
+	}
+
+	return node, pathParams
+}
+
+// matchSegments returns the accepted endpoint under node matching segments, trying the next
+// candidate when a branch leads nowhere, e.g : /users/42/profile matches /users/{name}/profile
+// even if /users/{id:int}/posts exists. Parameters are only captured on the way back up.
+func (r *Router) matchSegments(node *RouterNode, segments []string, pathParams map[string]string, accept func(n *RouterNode) bool) *RouterNode {
+	// Intermediate node, e.g : /users when only /users/{userId} was added
+	if len(segments) == 0 {
+		if node.isEndpoint() && accept(node) {
+			return node
 		}
 
+		return nil
 	}
 
-	h, ok := curNode.Handlers[method]
-	if !ok {
-		pathParams = nil
+	segment := segments[0]
+
+	if child, ok := node.Children[r.segmentKey(segment)]; ok {
+		if found := r.matchSegments(child, segments[1:], pathParams, accept); found != nil {
+			return found
+		}
+	}
+
+	if !isPathParam(segment) {
+		for _, child := range node.ParamChildren {
+			if !child.matchesConstraint(segment) {
+				continue
+			}
+
+			if found := r.matchSegments(child, segments[1:], pathParams, accept); found != nil {
+				pathParams[child.ParamaterName] = segment
+				return found
+			}
+		}
+	}
+
+	// Wildcards need at least one segment to capture, even an empty one: /static/ but not /static
+	if node.WildcardChild != nil && node.WildcardChild.isEndpoint() && accept(node.WildcardChild) {
+		pathParams[node.WildcardChild.ParamaterName] = strings.Join(segments, "/")
+		return node.WildcardChild
 	}
-	return h, pathParams, ok
+
+	return nil
 }
 
-func (r *Router) Handle(res ResponseWriter) {
-	defer res.Conn.Close()
+// paramChild returns the parameter child with the given constraint, or nil
+func (n *RouterNode) paramChild(constraint string) *RouterNode {
+	for _, child := range n.ParamChildren {
+		if child.Constraint == constraint {
+			return child
+		}
+	}
 
-	req, err := request.RequestFromReader(res.Conn)
-	if err != nil {
-		log.Printf("Error handling connection from %s: %v", res.Conn.RemoteAddr(), err)
+	return nil
+}
+
+// addParamChild keeps the unconstrained parameter, if any, last so it is tried after the others
+func (n *RouterNode) addParamChild(child *RouterNode) {
+	last := len(n.ParamChildren) - 1
+	if child.Constraint != "" && last >= 0 && n.ParamChildren[last].Constraint == "" {
+		n.ParamChildren = slices.Insert(n.ParamChildren, last, child)
 		return
 	}
 
-	// Wrap serveHttp in middleware
-	currentHandler := r.serveHttp
-	for i := len(r.GlobalMiddleware) - 1; i >= 0; i-- {
-		mw := r.GlobalMiddleware[i]
-		currentHandler = mw(currentHandler)
+	n.ParamChildren = append(n.ParamChildren, child)
+}
+
+func (n *RouterNode) matchesConstraint(value string) bool {
+	return n.constraintRegexp == nil || n.constraintRegexp.MatchString(value)
+}
+
+// serves reports whether n has a handler for method, HEAD being served by GET handlers
+func (n *RouterNode) serves(method HttpMethod) bool {
+	_, ok := n.Handlers[method]
+	if !ok && method == HEAD {
+		_, ok = n.Handlers[GET]
 	}
 
+	return ok || n.MountHandler != nil
+}
+
+func (n *RouterNode) isEndpoint() bool {
+	return len(n.Handlers) > 0 || n.MountHandler != nil
+}
+
+// AllowedMethods returns the sorted methods this node answers to, e.g : for the Allow header.
+// HEAD is served by GET handlers and OPTIONS is always answered by the router.
+func (n *RouterNode) AllowedMethods() []string {
+	methods := make([]string, 0, len(n.Handlers)+2)
+	for method := range n.Handlers {
+		methods = append(methods, string(method))
+	}
+
+	_, hasGet := n.Handlers[GET]
+	if _, ok := n.Handlers[HEAD]; !ok && hasGet {
+		methods = append(methods, string(HEAD))
+	}
+
+	if _, ok := n.Handlers[OPTIONS]; !ok {
+		methods = append(methods, string(OPTIONS))
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// Handle runs a single parsed request through the global middleware and the routing tree.
+// The connection itself is owned by the caller.
+func (r *Router) Handle(res ResponseWriter, req *request.Request) {
+	// Responses to HEAD never have a body, whatever the handler writes
+	if req.RequestLine.Method == string(HEAD) {
+		res.suppressBody()
+	}
+
+	// Wrap serveHttp in middleware
+	currentHandler := wrapHandler(r.serveHttp, r.GlobalMiddleware)
+
 	hErr := currentHandler(res, req)
 
 	if hErr != nil {
-		res.RespondWithHandleError(hErr)
+		r.respondWithError(res, hErr)
+		return
+	}
+}
+
+// HandleReadError answers a request that couldn't be read from the connection,
+// unless the client is already gone.
+func (r *Router) HandleReadError(res ResponseWriter, err error) {
+	hErr := NewReadError(err)
+	if hErr == nil {
 		return
 	}
+
+	r.respondWithError(res, hErr)
+}
+
+func (r *Router) respondWithError(res ResponseWriter, hErr *HandlerError) {
+	if r.ErrorHandler != nil {
+		r.ErrorHandler(res, hErr)
+		return
+	}
+
+	res.RespondWithHandleError(hErr)
 }
 
 func (r *Router) serveHttp(res ResponseWriter, req *request.Request) *HandlerError {
+	if r.KnownMethods != nil && !slices.Contains(r.KnownMethods, HttpMethod(req.RequestLine.Method)) {
+		return &HandlerError{
+			StatusCode: NotImplemented,
+			Message:    StatusText(NotImplemented),
+		}
+	}
+
+	if served, hErr := r.serveHost(res, req); served {
+		return hErr
+	}
+
+	path, redirect := r.canonicalPath(req)
+	if redirect {
+		redirectTo(res, req, path)
+		return nil
+	}
+
+	req.RequestLine.RequestTarget = path
+
+	return r.servePath(res, req, path, "")
+}
+
+// servePath routes req by path, which is the request target minus the prefix a router is mounted on.
+// The pattern of the route the prefix matched is prepended to the one set on req.
+func (r *Router) servePath(res ResponseWriter, req *request.Request, path string, patternPrefix string) *HandlerError {
 	method := HttpMethod(req.RequestLine.Method)
-	path := strings.ToLower(req.RequestLine.RequestTarget)
 
-	handler, pathParams, ok := r.GetHandlerAndPathParamsForPath(method, path)
+	node, pathParams := r.findNodeForMethod(path, method)
+
+	// No route serves method, answer for all the ones matching the path
+	if node == nil {
+		nodes := r.matchingNodes(path)
+		if len(nodes) > 0 {
+			return r.serveUnsupportedMethod(res, req, nodes, patternPrefix)
+		}
+
+		req.RoutePattern, req.RouteName = "", ""
+
+		if r.NotFound != nil {
+			return r.NotFound(res, req)
+		}
+		return DefaultNotFound(res, req)
+	}
+
+	req.RoutePattern = mountedPattern(patternPrefix, node.Pattern)
+
+	handler, ok := node.Handlers[method]
+	routeMethod := method
+
+	if !ok && method == HEAD {
+		handler, ok = node.Handlers[GET]
+		routeMethod = GET
+	}
 
-	// Retarded default message if path not find, TODO: change this
 	if !ok {
-		Custom404Response(res)
-		return nil
+		handler = node.MountHandler
+	}
+
+	// Parameters captured by the routers this one is mounted on are kept
+	if req.PathParams != nil {
+		pathParams = mergeParams(req.PathParams, pathParams)
 	}
 
 	req.PathParams = pathParams
+	req.RouteName = node.Names[routeMethod]
 	return handler(res, req)
 }
 
+// serveUnsupportedMethod answers OPTIONS, or 405 for other methods, with the methods
+// of all the routes matching the path in the Allow header
+func (r *Router) serveUnsupportedMethod(res ResponseWriter, req *request.Request, nodes []*RouterNode, patternPrefix string) *HandlerError {
+	req.RoutePattern, req.RouteName = mountedPattern(patternPrefix, nodes[0].Pattern), ""
+
+	var allowed []string
+	for _, node := range nodes {
+		allowed = append(allowed, node.AllowedMethods()...)
+	}
+	slices.Sort(allowed)
+	allowed = slices.Compact(allowed)
+
+	res.Headers.Add("Allow", strings.Join(allowed, ", "))
+
+	if HttpMethod(req.RequestLine.Method) == OPTIONS {
+		res.WriteHeaders(NoContent)
+		return nil
+	}
+
+	if r.MethodNotAllowed != nil {
+		return r.MethodNotAllowed(res, req)
+	}
+	return DefaultMethodNotAllowed(res, req)
+}
+
+func mergeParams(parent map[string]string, params map[string]string) map[string]string {
+	merged := maps.Clone(parent)
+	maps.Copy(merged, params)
+	return merged
+}
+
 func PrintRouterTree(node *RouterNode, indent string) {
 	segmentDisplay := node.Segment
 	if node.IsParamater {
 		segmentDisplay = fmt.Sprintf("{%s}", node.ParamaterName)
+		if node.Constraint != "" {
+			segmentDisplay = fmt.Sprintf("{%s:%s}", node.ParamaterName, node.Constraint)
+		}
+	}
+	if node.IsWildcard {
+		segmentDisplay = fmt.Sprintf("{%s...}", node.ParamaterName)
 	}
 	if segmentDisplay == "" {
 		segmentDisplay = "(root)" // Special case for the actual root
@@ -216,27 +799,13 @@ func PrintRouterTree(node *RouterNode, indent string) {
 		PrintRouterTree(child, indent+"  ") // Increase indent
 	}
 
-	// Print parameter child
-	if node.ParamChildren != nil {
-		PrintRouterTree(node.ParamChildren, indent+"  ") // Increase indent
+	// Print parameter children
+	for _, child := range node.ParamChildren {
+		PrintRouterTree(child, indent+"  ") // Increase indent
 	}
-}
-
-// Custom 404 if path not found
-func Custom404Response(res ResponseWriter) {
-	body := `<html>
-  <head>
-  </head>
-  <body>
-    <h1>404 NOT FOUND</h1>
-  </body>
-</html>`
 
-	bytes := []byte(body)
-
-	res.Headers.Add("Content-type", "text/html")
-	res.Headers.Add("Content-length", strconv.Itoa(len(bytes)))
-
-	res.WriteHeaders(BadRequest)
-	res.WriteBody(bytes)
+	// Print wildcard child
+	if node.WildcardChild != nil {
+		PrintRouterTree(node.WildcardChild, indent+"  ") // Increase indent
+	}
 }
@@ -1,8 +1,17 @@
 package response
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/Ciobi0212/httpfromtcp/headers"
 	"github.com/Ciobi0212/httpfromtcp/request"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,6 +24,27 @@ func dummyHandler3(w ResponseWriter, req *request.Request) *HandlerError { retur
 func dummyHandler4(w ResponseWriter, req *request.Request) *HandlerError { return nil }
 func dummyHandler5(w ResponseWriter, req *request.Request) *HandlerError { return nil }
 
+// captureResponse runs write against one end of an in-memory connection and returns
+// everything written to it
+func captureResponse(t *testing.T, write func(res ResponseWriter)) string {
+	server, client := net.Pipe()
+
+	go func() {
+		defer server.Close()
+		write(NewResponseWriter(server, false))
+	}()
+
+	raw, err := io.ReadAll(client)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func newTestRequest(t *testing.T, method HttpMethod, target string) *request.Request {
+	req, err := request.RequestFromReader(strings.NewReader(string(method) + " " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	return req
+}
+
 func TestRouter_AddAndGetHandler_Static(t *testing.T) {
 	router := NewRouter()
 
@@ -36,7 +66,7 @@ func TestRouter_AddAndGetHandler_Static(t *testing.T) {
 		{GET, "/users/profile", dummyHandler4, true},
 		{PUT, "/users", nil, false},       // Wrong method
 		{GET, "/nonexistent", nil, false}, // Wrong path
-		{GET, "/users/", nil, false},      // Trailing slash mismatch (current implementation)
+		{GET, "/users/", nil, false},      // Trailing slash mismatch, see Router.TrailingSlash
 	}
 
 	for _, tc := range tests {
@@ -69,10 +99,10 @@ func TestRouter_AddAndGetHandler_Param(t *testing.T) {
 		expectedParams map[string]string
 		found          bool
 	}{
-		{GET, "/users/123", dummyHandler1, map[string]string{"userid": "123"}, true},
-		{GET, "/users/abc", dummyHandler1, map[string]string{"userid": "abc"}, true},
-		{PUT, "/users/456/settings", dummyHandler2, map[string]string{"userid": "456"}, true},
-		{GET, "/products/xyz/details", dummyHandler3, map[string]string{"productid": "xyz"}, true},
+		{GET, "/users/123", dummyHandler1, map[string]string{"userId": "123"}, true},
+		{GET, "/users/abc", dummyHandler1, map[string]string{"userId": "abc"}, true},
+		{PUT, "/users/456/settings", dummyHandler2, map[string]string{"userId": "456"}, true},
+		{GET, "/products/xyz/details", dummyHandler3, map[string]string{"productId": "xyz"}, true},
 		{POST, "/users/123", nil, nil, false},                // Wrong method
 		{GET, "/users/123/nonexistent", nil, nil, false},     // Path doesn't match structure
 		{GET, "/products/xyz", nil, nil, false},              // Path doesn't match structure
@@ -135,3 +165,1034 @@ func TestRouter_MixedStaticAndParam(t *testing.T) {
 		assert.Equal(t, tc.expectedParams, params, "Path: %s, Method: %s", tc.path, tc.method)
 	}
 }
+
+func TestRouter_HandleReadError(t *testing.T) {
+	tests := []struct {
+		err                error
+		expectedStatusLine string
+	}{
+		{fmt.Errorf("error parsing: %w: bad", request.ErrMalformedRequestLine), "HTTP/1.1 400 Bad Request\r\n"},
+		{fmt.Errorf("error parsing: %w: HTTP/2.0", request.ErrUnsupportedVersion), "HTTP/1.1 505 HTTP Version Not Supported\r\n"},
+		{fmt.Errorf("error parsing: %w", request.ErrBodyTooLarge), "HTTP/1.1 413 Content Too Large\r\n"},
+		{fmt.Errorf("error parsing: %w", request.ErrHeadersTooLarge), "HTTP/1.1 431 Request Header Fields Too Large\r\n"},
+		{fmt.Errorf("error parsing: %w", request.ErrUnsupportedTransferEncoding), "HTTP/1.1 501 Not Implemented\r\n"},
+		{io.ErrUnexpectedEOF, ""}, // Client is gone, nothing to answer
+	}
+
+	router := NewRouter()
+	for _, tc := range tests {
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.HandleReadError(res, tc.err)
+		})
+
+		if tc.expectedStatusLine == "" {
+			assert.Empty(t, raw, "Error: %v", tc.err)
+			continue
+		}
+		assert.Contains(t, raw, tc.expectedStatusLine, "Error: %v", tc.err)
+		assert.Contains(t, raw, "connection: close\r\n", "Error: %v", tc.err)
+	}
+
+	// Test: Custom error body
+	router.ErrorHandler = func(w ResponseWriter, e *HandlerError) {
+		w.Headers.Add("Content-Length", "2")
+		w.WriteHeaders(e.StatusCode)
+		w.WriteBody([]byte("{}"))
+	}
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.HandleReadError(res, fmt.Errorf("%w: bad", request.ErrMalformedHeader))
+	})
+	assert.Contains(t, raw, "HTTP/1.1 400 Bad Request\r\n")
+	assert.Contains(t, raw, "\r\n\r\n{}")
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/users/{userId}", dummyHandler1)
+	router.AddHandler(PUT, "/users/{userId}", dummyHandler2)
+	router.AddHandler(DELETE, "/users/{userId}", dummyHandler3)
+
+	// Test: Path exists but not for this method
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, POST, "/users/123"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 405 Method Not Allowed\r\n"), raw)
+	assert.Contains(t, raw, "allow: DELETE, GET, HEAD, OPTIONS, PUT\r\n")
+
+	// Test: Intermediate node without handlers is still not found
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, POST, "/users"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 404 Not Found\r\n"), raw)
+	assert.NotContains(t, raw, "allow:")
+}
+
+func TestRouter_AutomaticHeadAndOptions(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/greet", func(w ResponseWriter, req *request.Request) *HandlerError {
+		w.Headers.Add("Content-Length", "5")
+		w.WriteHeaders(Ok)
+		w.WriteBody([]byte("hello"))
+		return nil
+	})
+	router.AddHandler(POST, "/submit", dummyHandler1)
+
+	// Test: HEAD falls back to GET, headers are kept but the body is dropped
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, HEAD, "/greet"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n"), raw)
+	assert.Contains(t, raw, "content-length: 5\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"), raw)
+	assert.NotContains(t, raw, "hello")
+
+	// Test: HEAD on a route without GET
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, HEAD, "/submit"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 405 Method Not Allowed\r\n"), raw)
+	assert.Contains(t, raw, "allow: OPTIONS, POST\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"), raw)
+
+	// Test: OPTIONS without explicit handler
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, OPTIONS, "/greet"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 204 No Content\r\n"), raw)
+	assert.Contains(t, raw, "allow: GET, HEAD, OPTIONS\r\n")
+
+	// Test: Explicit OPTIONS handler wins
+	router.AddHandler(OPTIONS, "/submit", func(w ResponseWriter, req *request.Request) *HandlerError {
+		w.WriteHeaders(Accepted)
+		return nil
+	})
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, OPTIONS, "/submit"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 202 Accepted\r\n"), raw)
+}
+
+func TestRouter_CaseSensitivity(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/Files/{fileName}", dummyHandler1)
+	router.AddHandler(GET, "/tokens/{token}", dummyHandler2)
+
+	tests := []struct {
+		method         HttpMethod
+		path           string
+		expectedParams map[string]string
+		found          bool
+	}{
+		{GET, "/Files/ReadMe.TXT", map[string]string{"fileName": "ReadMe.TXT"}, true},
+		{GET, "/files/ReadMe.TXT", nil, false}, // Static segments are case sensitive
+		{GET, "/tokens/aGVsbG8=", map[string]string{"token": "aGVsbG8="}, true},
+	}
+
+	for _, tc := range tests {
+		_, params, ok := router.GetHandlerAndPathParamsForPath(tc.method, tc.path)
+		assert.Equal(t, tc.found, ok, "Path: %s, Method: %s", tc.path, tc.method)
+		assert.Equal(t, tc.expectedParams, params, "Path: %s, Method: %s", tc.path, tc.method)
+	}
+
+	// Test: Case insensitive mode only folds static segments
+	router = NewRouter()
+	router.CaseInsensitive = true
+	router.AddHandler(GET, "/Files/{fileName}", dummyHandler1)
+
+	_, params, ok := router.GetHandlerAndPathParamsForPath(GET, "/FILES/ReadMe.TXT")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"fileName": "ReadMe.TXT"}, params)
+
+	req := newTestRequest(t, GET, "/files/ReadMe.TXT")
+	req.PathParams = params
+	assert.Equal(t, "ReadMe.TXT", req.GetPathParam("fileName"))
+}
+
+func TestRouter_Wildcard(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/static/{filePath...}", dummyHandler1)
+	router.AddHandler(GET, "/static/css/main.css", dummyHandler2)
+	router.AddHandler(GET, "/static/{section}/index.html", dummyHandler3)
+	router.AddHandler(GET, "/proxy/*rest", dummyHandler4)
+
+	tests := []struct {
+		method         HttpMethod
+		path           string
+		expectedParams map[string]string
+		found          bool
+	}{
+		{GET, "/static/css/main.css", map[string]string{}, true},                                     // Static wins
+		{GET, "/static/docs/index.html", map[string]string{"section": "docs"}, true},                 // Param wins
+		{GET, "/static/css/other.css", map[string]string{"filePath": "css/other.css"}, true},         // Static dead end, falls back
+		{GET, "/static/docs/img/logo.png", map[string]string{"filePath": "docs/img/logo.png"}, true}, // Param dead end, falls back
+		{GET, "/static/app.js", map[string]string{"filePath": "app.js"}, true},
+		{GET, "/static/", map[string]string{"filePath": ""}, true},
+		{GET, "/static", nil, false}, // Nothing left to capture
+		{GET, "/proxy/api/v1/Users", map[string]string{"rest": "api/v1/Users"}, true},
+		{POST, "/static/app.js", nil, false},
+	}
+
+	for _, tc := range tests {
+		handler, params, ok := router.GetHandlerAndPathParamsForPath(tc.method, tc.path)
+		assert.Equal(t, tc.found, ok, "Path: %s, Method: %s", tc.path, tc.method)
+		if tc.found {
+			require.NotNil(t, handler, "Path: %s, Method: %s", tc.path, tc.method)
+		}
+		assert.Equal(t, tc.expectedParams, params, "Path: %s, Method: %s", tc.path, tc.method)
+	}
+
+	// Test: Wildcard must be the last segment
+	assert.Panics(t, func() {
+		NewRouter().AddHandler(GET, "/files/{rest...}/more", dummyHandler1)
+	})
+
+	// Test: Conflicting wildcard names at the same level
+	assert.Panics(t, func() {
+		router.AddHandler(POST, "/static/*other", dummyHandler1)
+	})
+}
+
+func TestRouter_ParamConstraints(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/users/{name}/profile", dummyHandler1)
+	router.AddHandler(GET, "/users/{id:int}/posts", dummyHandler2)
+	router.AddHandler(GET, "/users/{uuid:uuid}/posts", dummyHandler3)
+	router.AddHandler(GET, "/articles/{slug:[a-z-]+}", dummyHandler4)
+
+	tests := []struct {
+		path           string
+		expectedParams map[string]string
+		found          bool
+	}{
+		{"/users/42/posts", map[string]string{"id": "42"}, true},
+		{"/users/-7/posts", map[string]string{"id": "-7"}, true},
+		{"/users/3f2504e0-4f89-11d3-9a0c-0305e82c3301/posts", map[string]string{"uuid": "3f2504e0-4f89-11d3-9a0c-0305e82c3301"}, true},
+		{"/users/abc/posts", nil, false},                                          // Neither int nor uuid
+		{"/users/42/profile", map[string]string{"name": "42"}, true},              // Int branch dead end, unconstrained is tried next
+		{"/articles/hello-world", map[string]string{"slug": "hello-world"}, true}, // Regex constraint
+		{"/articles/Hello", nil, false},                                           // Whole segment must match
+	}
+
+	for _, tc := range tests {
+		_, params, ok := router.GetHandlerAndPathParamsForPath(GET, tc.path)
+		assert.Equal(t, tc.found, ok, "Path: %s", tc.path)
+		assert.Equal(t, tc.expectedParams, params, "Path: %s", tc.path)
+	}
+
+	// Test: Constrained parameters are tried before the unconstrained one, in the order they were added
+	users := router.Root().Children["/"].Children["users"]
+	require.Len(t, users.ParamChildren, 3)
+	assert.Equal(t, "int", users.ParamChildren[0].Constraint)
+	assert.Equal(t, "uuid", users.ParamChildren[1].Constraint)
+	assert.Empty(t, users.ParamChildren[2].Constraint)
+
+	// Test: Same constraint with another name conflicts, invalid regexes are rejected
+	assert.Panics(t, func() { router.AddHandler(GET, "/users/{userId:int}", dummyHandler1) })
+	assert.Panics(t, func() { router.AddHandler(GET, "/broken/{id:[a-z}", dummyHandler1) })
+}
+
+func TestRouter_URL(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/", dummyHandler1).Name("home")
+	router.AddHandler(GET, "/users/{id:int}/settings", dummyHandler2).Name("user-settings")
+	router.AddHandler(GET, "/users/{name}/profile", dummyHandler3).Name("user-profile")
+	router.Group("/static").AddHandler(GET, "/{filePath...}", dummyHandler4).Name("static")
+
+	tests := []struct {
+		name     string
+		params   map[string]string
+		query    url.Values
+		expected string
+	}{
+		{"home", nil, nil, "/"},
+		{"user-settings", map[string]string{"id": "42"}, nil, "/users/42/settings"},
+		{"user-settings", map[string]string{"id": "42"}, url.Values{"tab": {"privacy & security"}}, "/users/42/settings?tab=privacy+%26+security"},
+		{"user-profile", map[string]string{"name": "john doe/admin"}, nil, "/users/john%20doe%2Fadmin/profile"},
+		{"static", map[string]string{"filePath": "css/main file.css"}, nil, "/static/css/main%20file.css"},
+	}
+
+	for _, tc := range tests {
+		path, err := router.URL(tc.name, tc.params, tc.query)
+		require.NoError(t, err, "Route: %s", tc.name)
+		assert.Equal(t, tc.expected, path)
+	}
+
+	// Test: Built paths route back to the same handler and params
+	path, err := router.URL("user-profile", map[string]string{"name": "jane"}, nil)
+	require.NoError(t, err)
+	_, params, ok := router.GetHandlerAndPathParamsForPath(GET, path)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"name": "jane"}, params)
+
+	// Test: Errors
+	_, err = router.URL("missing", nil, nil)
+	assert.ErrorIs(t, err, ErrUnknownRoute)
+	_, err = router.URL("user-settings", nil, nil)
+	assert.ErrorIs(t, err, ErrMissingParam)
+	_, err = router.URL("user-settings", map[string]string{"id": "abc"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidParam)
+
+	// Test: Renaming a route frees its previous name, names are unique
+	router.AddHandler(GET, "/users/{name}/profile", dummyHandler3).Name("profile")
+	_, err = router.URL("user-profile", map[string]string{"name": "jane"}, nil)
+	assert.ErrorIs(t, err, ErrUnknownRoute)
+	assert.Panics(t, func() { router.AddHandler(POST, "/other", dummyHandler1).Name("profile") })
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/", "/"},
+		{"/users", "/users"},
+		{"/users/", "/users/"},
+		{"//users//42", "/users/42"},
+		{"/users/./42", "/users/42"},
+		{"//users/../admin", "/admin"},
+		{"/a/b/../../../../etc/passwd", "/etc/passwd"},
+		{"/static/%2e%2E/secret", "/secret"},
+		{"/users/.", "/users/"},
+		{"/users/..", "/"},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, cleanPath(tc.path), "Path: %s", tc.path)
+	}
+}
+
+func TestRouter_PathPolicies(t *testing.T) {
+	router := NewRouter()
+	router.AddHandler(GET, "/users", dummyHandler1)
+	router.AddHandler(POST, "/users", dummyHandler1)
+	router.AddHandler(GET, "/admin/", dummyHandler2)
+
+	// Test: Paths are routed as sent by default
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/users/"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 404 Not Found\r\n"), raw)
+
+	// Test: Redirects keep the query, and use 308 so other methods are replayed
+	router.CleanPath = PathPolicyRedirect
+	router.TrailingSlash = PathPolicyRedirect
+
+	tests := []struct {
+		method   HttpMethod
+		target   string
+		status   string
+		location string
+	}{
+		{GET, "/users/?page=2", "301 Moved Permanently", "/users?page=2"},
+		{GET, "/admin", "301 Moved Permanently", "/admin/"},
+		{GET, "//users/../admin/.", "301 Moved Permanently", "/admin/"},
+		{POST, "/api/../users/", "308 Permanent Redirect", "/users"},
+	}
+
+	for _, tc := range tests {
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, tc.method, tc.target))
+		})
+		assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 "+tc.status+"\r\n"), raw)
+		assert.Contains(t, raw, "location: "+tc.location+"\r\n")
+	}
+
+	// Test: Redirects never leave the host, even when duplicate slashes are kept
+	router.CleanPath = PathPolicyNone
+	router.AddHandler(GET, "/{org}/{repo}", dummyHandler3)
+
+	for target, location := range map[string]string{"//evil.com/": "/evil.com", "/\\evil.com": "/evil.com/"} {
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, GET, target))
+		})
+		assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 301 Moved Permanently\r\n"), raw)
+		assert.Contains(t, raw, "location: "+location+"\r\n")
+	}
+
+	// Test: Canonical paths are matched silently
+	router.CleanPath = PathPolicyMatch
+	router.TrailingSlash = PathPolicyMatch
+
+	var target string
+	router.AddHandler(GET, "/files/{name}", func(w ResponseWriter, req *request.Request) *HandlerError {
+		target = req.RequestLine.RequestTarget
+		w.WriteHeaders(NoContent)
+		return nil
+	})
+
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/static/..//files/a.txt/"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 204 No Content\r\n"), raw)
+	assert.Equal(t, "/files/a.txt", target)
+}
+
+func TestRouter_Routes(t *testing.T) {
+	router := NewRouter()
+	noop := func(next Handler) Handler { return next }
+
+	router.AddHandler(GET, "/", dummyHandler1)
+	router.AddHandler(POST, "/users", dummyHandler2, noop).Name("create-user")
+	router.Group("/users", noop).AddHandler(GET, "/{id:int}/files/{filePath...}", dummyHandler3, noop).
+		Doc(RouteDoc{Summary: "Download a file"})
+	router.AddHandler(GET, "/users", dummyHandler4)
+
+	expected := []RouteInfo{
+		{Method: GET, Pattern: "/"},
+		{Method: GET, Pattern: "/users"},
+		{Method: POST, Pattern: "/users", Name: "create-user", MiddlewareCount: 1},
+		{
+			Method:          GET,
+			Pattern:         "/users/{id:int}/files/{filePath...}",
+			Params:          []string{"id", "filePath"},
+			MiddlewareCount: 2,
+			Doc:             &RouteDoc{Summary: "Download a file"},
+		},
+	}
+	assert.Equal(t, expected, router.Routes())
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/users/{id:int}", dummyHandler1).Name("get-user").Doc(RouteDoc{
+		Summary:         "Get a user",
+		ResponseSchemas: map[StatusCode]string{Ok: "#/components/schemas/User"},
+	})
+	router.AddHandler(PUT, "/users/{id:int}", dummyHandler2).Doc(RouteDoc{
+		RequestSchema: "#/components/schemas/User",
+	})
+	router.AddHandler(GET, "/posts/{slug:[a-z-]+}", dummyHandler3)
+
+	raw, err := router.OpenAPI("Users API", "1.0.0")
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(raw, &doc))
+
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Equal(t, map[string]any{"title": "Users API", "version": "1.0.0"}, doc["info"])
+
+	paths := doc["paths"].(map[string]any)
+	require.Contains(t, paths, "/users/{id}")
+	require.Contains(t, paths, "/posts/{slug}")
+
+	get := paths["/users/{id}"].(map[string]any)["get"].(map[string]any)
+	assert.Equal(t, "get-user", get["operationId"])
+	assert.Equal(t, "Get a user", get["summary"])
+	assert.Equal(t, []any{map[string]any{
+		"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "integer"},
+	}}, get["parameters"])
+	assert.Equal(t, map[string]any{"200": map[string]any{
+		"description": "OK",
+		"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/User"}}},
+	}}, get["responses"])
+
+	put := paths["/users/{id}"].(map[string]any)["put"].(map[string]any)
+	assert.Contains(t, put, "requestBody")
+	assert.Contains(t, put["responses"], "default")
+
+	slug := paths["/posts/{slug}"].(map[string]any)["get"].(map[string]any)["parameters"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "pattern": "^(?:[a-z-]+)$"}, slug["schema"])
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	assert.Equal(t, map[string]any{"User": map[string]any{"type": "object"}}, schemas)
+}
+
+func TestRouter_Host(t *testing.T) {
+	router := NewRouter()
+
+	serveName := func(name string) Handler {
+		return func(w ResponseWriter, req *request.Request) *HandlerError {
+			body := name + " " + req.PathParams[SubdomainParam]
+			w.Headers.Add("Content-Length", fmt.Sprint(len(body)))
+			w.WriteHeaders(Ok)
+			w.WriteBody([]byte(body))
+			return nil
+		}
+	}
+
+	router.AddHandler(GET, "/", serveName("default"))
+	router.Host("api.example.com").AddHandler(GET, "/", serveName("api"))
+	router.Host("*.example.com").AddHandler(GET, "/", serveName("example"))
+	router.Host("*.tenant.example.com").AddHandler(GET, "/", serveName("tenant"))
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"api.example.com", "api "},
+		{"API.Example.com:8080", "api "},
+		{"www.example.com", "example www"},
+		{"acme.tenant.example.com", "tenant acme"},
+		{"a.b.example.com", "default "}, // Wildcards match a single label
+		{"example.com", "default "},
+		{"localhost", "default "},
+	}
+
+	for _, tc := range tests {
+		req, err := request.RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: " + tc.host + "\r\n\r\n"))
+		require.NoError(t, err)
+
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, req)
+		})
+		assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"+tc.expected), "Host: %s, %s", tc.host, raw)
+	}
+
+	// Test: HTTP/1.1 requests need exactly one Host header
+	for _, rawRequest := range []string{
+		"GET / HTTP/1.1\r\n\r\n",
+		"GET / HTTP/1.1\r\nHost: a.example.com\r\nHost: b.example.com\r\n\r\n",
+	} {
+		req, err := request.RequestFromReader(strings.NewReader(rawRequest))
+		require.NoError(t, err)
+
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, req)
+		})
+		assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 400 Bad Request\r\n"), raw)
+	}
+}
+
+func TestRouter_RemoveHandler(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(GET, "/users/{id:int}/settings", dummyHandler1).Name("user-settings")
+	router.AddHandler(POST, "/users/{id:int}/settings", dummyHandler2)
+	router.AddHandler(GET, "/users", dummyHandler3)
+
+	// Test: Only the given method is removed, with its name
+	assert.True(t, router.RemoveHandler(GET, "/users/{id:int}/settings"))
+	_, _, ok := router.GetHandlerAndPathParamsForPath(GET, "/users/42/settings")
+	assert.False(t, ok)
+	_, _, ok = router.GetHandlerAndPathParamsForPath(POST, "/users/42/settings")
+	assert.True(t, ok)
+	_, err := router.URL("user-settings", map[string]string{"id": "42"}, nil)
+	assert.ErrorIs(t, err, ErrUnknownRoute)
+
+	// Test: Unknown routes, nodes without handlers and mismatched parameters are left alone
+	assert.False(t, router.RemoveHandler(GET, "/users/{id:int}/settings"))
+	assert.False(t, router.RemoveHandler(GET, "/users/{id:int}"))
+	assert.False(t, router.RemoveHandler(POST, "/users/{userId:int}/settings"))
+	assert.False(t, router.RemoveHandler(GET, "/missing"))
+
+	// Test: Nodes left without routes are pruned
+	assert.True(t, router.RemoveHandler(POST, "/users/{id:int}/settings"))
+	assert.Empty(t, router.Root().Children["/"].Children["users"].ParamChildren)
+
+	// Test: The tree being served is never modified
+	served := router.Root()
+	router.AddHandler(GET, "/users/{id:int}", dummyHandler1)
+	assert.Empty(t, served.Children["/"].Children["users"].ParamChildren)
+	assert.Len(t, router.Root().Children["/"].Children["users"].ParamChildren, 1)
+}
+
+func TestRouter_ConcurrentChanges(t *testing.T) {
+	router := NewRouter()
+	router.AddHandler(GET, "/static", dummyHandler1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			path := fmt.Sprintf("/feature/%d", i%5)
+			router.AddHandler(GET, path, dummyHandler2).Name(path).Doc(RouteDoc{Summary: path})
+			router.RemoveHandler(GET, path)
+		}
+	}()
+
+	// Test: Requests keep being served while routes change, run with -race
+	for i := 0; i < 100; i++ {
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, GET, "/static"))
+		})
+		assert.NotContains(t, raw, "404")
+		router.Routes()
+	}
+
+	<-done
+}
+
+func TestRouter_Methods(t *testing.T) {
+	router := NewRouter()
+	noContent := func(w ResponseWriter, req *request.Request) *HandlerError {
+		w.WriteHeaders(NoContent)
+		return nil
+	}
+
+	router.AddHandlerMethods([]HttpMethod{PUT, PATCH}, "/users/{id}", noContent)
+	router.Any("/echo", dummyHandler2)
+	router.AddHandler(PROPFIND, "/dav", noContent)
+
+	for _, method := range []HttpMethod{PUT, PATCH} {
+		_, _, ok := router.GetHandlerAndPathParamsForPath(method, "/users/42")
+		assert.True(t, ok, "Method: %s", method)
+	}
+	for _, method := range StandardMethods() {
+		_, _, ok := router.GetHandlerAndPathParamsForPath(method, "/echo")
+		assert.True(t, ok, "Method: %s", method)
+	}
+
+	tests := []struct {
+		method HttpMethod
+		target string
+		status string
+	}{
+		{PROPFIND, "/dav", "HTTP/1.1 204 No Content"},
+		{PATCH, "/users/42", "HTTP/1.1 204 No Content"},
+		{"BREW", "/echo", "HTTP/1.1 501 Not Implemented"}, // Unknown methods aren't routed
+		{"BREW", "/missing", "HTTP/1.1 501 Not Implemented"},
+		{DELETE, "/users/42", "HTTP/1.1 405 Method Not Allowed"},
+	}
+
+	for _, tc := range tests {
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, tc.method, tc.target))
+		})
+		assert.True(t, strings.HasPrefix(raw, tc.status+"\r\n"), "%s %s: %s", tc.method, tc.target, raw)
+	}
+
+	// Test: Custom methods can't be registered until they are known
+	assert.Panics(t, func() { router.AddHandler("BREW", "/coffee", dummyHandler4) })
+
+	// Test: Custom methods are routed once known, any method is if the allowlist is nil
+	router.KnownMethods = append(DefaultKnownMethods(), "BREW")
+	router.AddHandler("BREW", "/coffee", dummyHandler4)
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, "BREW", "/coffee"))
+	})
+	assert.NotContains(t, raw, "501")
+
+	router.KnownMethods = nil
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, "BREW", "/missing"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 404 Not Found\r\n"), raw)
+}
+
+func TestRouter_MatchedRoute(t *testing.T) {
+	router := NewRouter()
+
+	var pattern, name string
+	router.Use(func(next Handler) Handler {
+		return func(w ResponseWriter, req *request.Request) *HandlerError {
+			hErr := next(w, req)
+			pattern, name = req.RoutePattern, req.RouteName
+			return hErr
+		}
+	})
+
+	router.AddHandler(GET, "/users/{userId:int}", dummyHandler1).Name("get-user")
+	router.AddHandler(POST, "/users/{userId:int}", dummyHandler2)
+
+	sub := NewRouter()
+	sub.AddHandler(GET, "/orders/{orderId}", dummyHandler3).Name("get-order")
+	sub.AddHandler(GET, "/", dummyHandler4)
+	router.Mount("/shops/{shopId}", sub)
+
+	tests := []struct {
+		method  HttpMethod
+		target  string
+		pattern string
+		name    string
+	}{
+		{GET, "/users/42", "/users/{userId:int}", "get-user"},
+		{HEAD, "/users/42", "/users/{userId:int}", "get-user"}, // Served by GET
+		{POST, "/users/42", "/users/{userId:int}", ""},
+		{DELETE, "/users/42", "/users/{userId:int}", ""}, // Not allowed, the path still matched
+		{GET, "/shops/7/orders/1", "/shops/{shopId}/orders/{orderId}", "get-order"},
+		{GET, "/shops/7", "/shops/{shopId}", ""}, // The sub-router's root is reported as the prefix
+		{GET, "/shops/7/", "/shops/{shopId}", ""},
+		{GET, "/shops/7/missing", "", ""},
+		{GET, "/missing", "", ""},
+	}
+
+	for _, tc := range tests {
+		captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, tc.method, tc.target))
+		})
+		assert.Equal(t, tc.pattern, pattern, "%s %s", tc.method, tc.target)
+		assert.Equal(t, tc.name, name, "%s %s", tc.method, tc.target)
+	}
+}
+
+func TestRouter_NotFoundAndMethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+	router.Use(func(next Handler) Handler {
+		return func(w ResponseWriter, req *request.Request) *HandlerError {
+			w.Headers.Add("Access-Control-Allow-Origin", "*")
+			return next(w, req)
+		}
+	})
+	router.AddHandler(GET, "/users", dummyHandler1)
+
+	// Test: Default 404 follows Accept, after the global middleware
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "content-type: text/plain\r\n"},
+		{"*/*", "content-type: text/plain\r\n"},
+		{"application/json", "content-type: application/json\r\n"},
+		{"text/html, application/*;q=0.9", "content-type: application/json\r\n"},
+		{"application/json;q=0.5, text/plain", "content-type: text/plain\r\n"},
+	}
+
+	for _, tc := range tests {
+		req := newTestRequest(t, GET, "/missing")
+		if tc.accept != "" {
+			req.Headers.Add("Accept", tc.accept)
+		}
+
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, req)
+		})
+		assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 404 Not Found\r\n"), raw)
+		assert.Contains(t, raw, tc.expected, "Accept: %s", tc.accept)
+		assert.Contains(t, raw, "access-control-allow-origin: *\r\n")
+	}
+
+	req := newTestRequest(t, GET, "/missing")
+	req.Headers.Add("Accept", "application/json")
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, req)
+	})
+	assert.True(t, strings.HasSuffix(raw, `{"error":"Not Found","status":404}`), raw)
+
+	// Test: Deprecated helper writes the same 404
+	raw = captureResponse(t, func(res ResponseWriter) {
+		Custom404Response(res)
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 404 Not Found\r\n"), raw)
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\nNot Found"), raw)
+
+	// Test: Custom handlers, the Allow header is set for MethodNotAllowed
+	router.NotFound = func(w ResponseWriter, req *request.Request) *HandlerError {
+		return &HandlerError{StatusCode: NotFound, Message: "no such page: " + req.RequestLine.RequestTarget}
+	}
+	router.MethodNotAllowed = func(w ResponseWriter, req *request.Request) *HandlerError {
+		return &HandlerError{StatusCode: MethodNotAllowed, Message: "try " + w.Headers.Get("Allow")}
+	}
+
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/missing"))
+	})
+	assert.True(t, strings.HasSuffix(raw, "no such page: /missing"), raw)
+	assert.Contains(t, raw, "access-control-allow-origin: *\r\n")
+
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, DELETE, "/users"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 405 Method Not Allowed\r\n"), raw)
+	assert.True(t, strings.HasSuffix(raw, "try GET, HEAD, OPTIONS"), raw)
+}
+
+func TestRouter_MethodAwareMatching(t *testing.T) {
+	router := NewRouter()
+
+	var served string
+	serve := func(name string) Handler {
+		return func(w ResponseWriter, req *request.Request) *HandlerError {
+			served = name + " " + req.RoutePattern
+			w.WriteHeaders(NoContent)
+			return nil
+		}
+	}
+
+	router.AddHandler(GET, "/static/{filePath...}", serve("files"))
+	router.AddHandler(POST, "/static/upload", serve("upload"))
+	router.AddHandler(PUT, "/items/{id}", serve("item"))
+	router.AddHandler(DELETE, "/items/special", serve("special"))
+
+	// Test: A route only catches the methods it serves, the others fall through to the next candidate
+	tests := []struct {
+		method   HttpMethod
+		target   string
+		expected string
+	}{
+		{GET, "/static/upload", "files /static/{filePath...}"},
+		{HEAD, "/static/upload", "files /static/{filePath...}"},
+		{POST, "/static/upload", "upload /static/upload"},
+		{PUT, "/items/special", "item /items/{id}"},
+		{DELETE, "/items/special", "special /items/special"},
+	}
+
+	for _, tc := range tests {
+		served = ""
+		raw := captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, tc.method, tc.target))
+		})
+		assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 204 No Content\r\n"), "%s %s: %s", tc.method, tc.target, raw)
+		assert.Equal(t, tc.expected, served, "%s %s", tc.method, tc.target)
+	}
+
+	// Test: 405 only when no candidate serves the method, Allow lists them all
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, PATCH, "/items/special"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 405 Method Not Allowed\r\n"), raw)
+	assert.Contains(t, raw, "allow: DELETE, OPTIONS, PUT\r\n")
+
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, OPTIONS, "/static/upload"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 204 No Content\r\n"), raw)
+	assert.Contains(t, raw, "allow: GET, HEAD, OPTIONS, POST\r\n")
+
+	handler, params, ok := router.GetHandlerAndPathParamsForPath(GET, "/static/upload")
+	assert.True(t, ok)
+	assert.NotNil(t, handler)
+	assert.Equal(t, map[string]string{"filePath": "upload"}, params)
+}
+
+func TestRouter_Group(t *testing.T) {
+	router := NewRouter()
+
+	// Middleware recording the order in which it ran
+	var calls []string
+	recorder := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w ResponseWriter, req *request.Request) *HandlerError {
+				calls = append(calls, name)
+				return next(w, req)
+			}
+		}
+	}
+
+	router.Use(recorder("global"))
+	router.AddHandler(GET, "/public", dummyHandler1)
+
+	api := router.Group("/api/v1/", recorder("api"))
+	api.AddHandler(GET, "/users/{userId}", dummyHandler2)
+
+	admin := api.Group("/admin", recorder("auth"))
+	admin.Use(recorder("audit"))
+	admin.AddHandler(DELETE, "/users/{userId}", dummyHandler3)
+
+	tests := []struct {
+		method        HttpMethod
+		path          string
+		expectedCalls []string
+	}{
+		{GET, "/public", []string{"global"}},
+		{GET, "/api/v1/users/42", []string{"global", "api"}},
+		{DELETE, "/api/v1/admin/users/42", []string{"global", "api", "auth", "audit"}},
+		{GET, "/api/v1/admin/users/42", []string{"global"}}, // Group middleware doesn't run on 405
+	}
+
+	for _, tc := range tests {
+		calls = nil
+		captureResponse(t, func(res ResponseWriter) {
+			router.Handle(res, newTestRequest(t, tc.method, tc.path))
+		})
+		assert.Equal(t, tc.expectedCalls, calls, "Path: %s, Method: %s", tc.path, tc.method)
+	}
+
+	_, params, ok := router.GetHandlerAndPathParamsForPath(DELETE, "/api/v1/admin/users/42")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"userId": "42"}, params)
+}
+
+func TestRouter_RouteMiddleware(t *testing.T) {
+	router := NewRouter()
+
+	rateLimited := func(next Handler) Handler {
+		return func(w ResponseWriter, req *request.Request) *HandlerError {
+			w.Headers.Add("X-RateLimit-Remaining", "0")
+			return &HandlerError{StatusCode: TooManyRequests, Message: "slow down"}
+		}
+	}
+
+	router.AddHandler(POST, "/login", dummyHandler1, rateLimited)
+	router.AddHandler(GET, "/login", dummyHandler2)
+
+	// Test: Middleware only runs for the route and method it was given with
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, POST, "/login"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 429 Too Many Requests\r\n"), raw)
+	assert.Contains(t, raw, "x-ratelimit-remaining: 0\r\n")
+
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/login"))
+	})
+	assert.NotContains(t, raw, "429")
+
+	// Test: Middleware is stored on the node
+	node, _ := router.findNode("/login")
+	require.NotNil(t, node)
+	assert.Len(t, node.Middleware[POST], 1)
+	assert.Empty(t, node.Middleware[GET])
+}
+
+func TestRouter_Mount(t *testing.T) {
+	router := NewRouter()
+	router.AddHandler(GET, "/tenants/{tenantId}/status", dummyHandler1)
+
+	sub := NewRouter()
+	sub.Use(func(next Handler) Handler {
+		return func(w ResponseWriter, req *request.Request) *HandlerError {
+			w.Headers.Add("X-Sub", "true")
+			return next(w, req)
+		}
+	})
+	sub.AddHandler(GET, "/", func(w ResponseWriter, req *request.Request) *HandlerError {
+		w.Headers.Add("Content-Length", "4")
+		w.WriteHeaders(Ok)
+		w.WriteBody([]byte("root"))
+		return nil
+	})
+	sub.AddHandler(GET, "/users/{userId}", func(w ResponseWriter, req *request.Request) *HandlerError {
+		body := req.GetPathParam("tenantId") + "/" + req.GetPathParam("userId") + " " + req.RequestLine.RequestTarget
+		w.Headers.Add("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeaders(Ok)
+		w.WriteBody([]byte(body))
+		return nil
+	})
+	router.Mount("/tenants/{tenantId}/", sub)
+
+	// Test: Sub-router routes on the rest of the path, parent params are kept
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/tenants/acme/users/42"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n"), raw)
+	assert.Contains(t, raw, "x-sub: true\r\n")
+	assert.True(t, strings.HasSuffix(raw, "acme/42 /tenants/acme/users/42"), raw)
+
+	// Test: Mount point itself is the root of the sub-router
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/tenants/acme"))
+	})
+	assert.True(t, strings.HasSuffix(raw, "root"), raw)
+
+	// Test: Parent routes under the prefix take precedence
+	_, _, ok := router.GetHandlerAndPathParamsForPath(GET, "/tenants/acme/status")
+	assert.True(t, ok)
+
+	// Test: Sub-router answers 405 for its own routes
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, POST, "/tenants/acme/users/42"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 405 Method Not Allowed\r\n"), raw)
+}
+
+func TestRouter_MountHTTP(t *testing.T) {
+	router := NewRouter()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path":%q,"q":%q}`, r.URL.Path, r.URL.Query().Get("q"))
+	})
+	mux.HandleFunc("/debug/files/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(r.URL.Path)))
+		w.Write([]byte(r.URL.Path))
+	})
+	router.MountHTTP("/debug", mux)
+
+	// Test: Handler sees the full path and query, body without length is sent chunked
+	raw := captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/debug/vars?q=a%20b"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n"), raw)
+	assert.Contains(t, raw, "content-type: application/json\r\n")
+	assert.Contains(t, raw, "transfer-encoding: chunked\r\n")
+	assert.Contains(t, raw, `{"path":"/debug/vars","q":"a b"}`)
+	assert.True(t, strings.HasSuffix(raw, "0\r\n\r\n"), raw)
+
+	// Test: Escaped paths are unescaped in URL.Path, like net/http does
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, GET, "/debug/files/a%20b"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n"), raw)
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n/debug/files/a b"), raw)
+
+	// Test: Standard library 404
+	raw = captureResponse(t, func(res ResponseWriter) {
+		router.Handle(res, newTestRequest(t, POST, "/debug/missing"))
+	})
+	assert.True(t, strings.HasPrefix(raw, "HTTP/1.1 404 Not Found\r\n"), raw)
+}
+
+func TestToHTTPHandler(t *testing.T) {
+	router := NewRouter()
+
+	router.AddHandler(POST, "/users/{userId}", func(w ResponseWriter, req *request.Request) *HandlerError {
+		body := fmt.Sprintf("%s %s %s", req.PathParams["userId"], req.QueryParams["q"], req.Body)
+		w.Headers.Add("Content-Type", "text/plain")
+		w.Headers.Add("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeaders(Created)
+		w.WriteBody([]byte(body))
+		return nil
+	})
+	router.AddHandler(GET, "/stream", func(w ResponseWriter, req *request.Request) *HandlerError {
+		w.Headers.Add("Transfer-Encoding", "chunked")
+		w.Headers.Add("Trailer", "X-Checksum")
+		w.WriteHeaders(Ok)
+		w.WriteChunkedBody([]byte("hello "))
+		w.WriteChunkedBody([]byte("world"))
+		w.WriteChunkedBodyDone()
+		trailers := headers.NewHeaders()
+		trailers.Add("X-Checksum", "abc")
+		w.WriteTrailers(trailers)
+		return nil
+	})
+
+	handler := ToHTTPHandler(router)
+	handler.MaxBodyBytes = 16
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// Test: Path params, query params and body reach the handler
+	resp, err := http.Post(srv.URL+"/users/42?q=a%20b", "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "42 a b payload", string(body))
+
+	// Test: Repeated query keys keep the last value, like the native parser
+	resp, err = http.Post(srv.URL+"/users/42?q=first&q=last", "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "42 last payload", string(body))
+
+	// Test: Bodies over the limit aren't buffered
+	resp, err = http.Post(srv.URL+"/users/42", "text/plain", strings.NewReader(strings.Repeat("a", 17)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// Test: Chunked bodies and trailers go through the standard library
+	resp, err = http.Get(srv.URL + "/stream")
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "hello world", string(body))
+	assert.Equal(t, "abc", resp.Trailer.Get("X-Checksum"))
+
+	// Test: Router errors and automatic responses
+	resp, err = http.Get(srv.URL + "/users/42")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "OPTIONS, POST", resp.Header.Get("Allow"))
+
+	resp, err = http.Get(srv.URL + "/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+package response
+
+import (
+	"slices"
+	"strings"
+)
+
+// RouteInfo describes a route added to a Router, see Router.Routes
+type RouteInfo struct {
+	Method HttpMethod
+
+	// E.g : "/users/{id:int}/files/{filePath...}"
+	Pattern string
+
+	// Names of the path parameters in order, e.g : "id, filePath"
+	Params []string
+
+	// Empty if the route wasn't named
+	Name string
+
+	// Route and group middleware, global middleware are not counted
+	MiddlewareCount int
+
+	// Nil if the route wasn't documented
+	Doc *RouteDoc
+}
+
+// RouteDoc documents a route, e.g : for Router.OpenAPI
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Reference of the JSON request body schema, e.g : "#/components/schemas/User"
+	RequestSchema string
+
+	// References of the JSON response schemas by status code, e.g : Ok: "#/components/schemas/User"
+	ResponseSchemas map[StatusCode]string
+}
+
+// Doc attaches documentation to the route, unless it was removed since
+func (rt *Route) Doc(doc RouteDoc) *Route {
+	r := rt.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chain := r.copyPath(rt.Pattern, false)
+	if chain == nil {
+		return rt
+	}
+
+	node := chain[len(chain)-1]
+	if _, ok := node.Handlers[rt.Method]; !ok {
+		return rt
+	}
+
+	node.Docs[rt.Method] = &doc
+	r.root.Store(chain[0])
+
+	return rt
+}
+
+// Routes returns the routes added to the router sorted by pattern and method. Mounted routers
+// and the HEAD and OPTIONS methods answered automatically are not listed.
+func (r *Router) Routes() []RouteInfo {
+	routes := []RouteInfo{}
+
+	for _, child := range r.Root().Children {
+		routes = collectRoutes(child, "", nil, routes)
+	}
+
+	slices.SortFunc(routes, func(a, b RouteInfo) int {
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Method), string(b.Method))
+	})
+
+	return routes
+}
+
+// collectRoutes appends the routes of node and its descendants, pattern and params being those of its parent
+func collectRoutes(node *RouterNode, pattern string, params []string, routes []RouteInfo) []RouteInfo {
+	switch {
+	case node.IsWildcard:
+		pattern += "/{" + node.ParamaterName + "...}"
+		params = append(slices.Clone(params), node.ParamaterName)
+	case node.IsParamater && node.Constraint != "":
+		pattern += "/{" + node.ParamaterName + ":" + node.Constraint + "}"
+		params = append(slices.Clone(params), node.ParamaterName)
+	case node.IsParamater:
+		pattern += "/{" + node.ParamaterName + "}"
+		params = append(slices.Clone(params), node.ParamaterName)
+	case node.Segment != "/":
+		pattern += "/" + node.Segment
+	}
+
+	for method := range node.Handlers {
+		routePattern := pattern
+		if routePattern == "" {
+			routePattern = "/"
+		}
+
+		routes = append(routes, RouteInfo{
+			Method:          method,
+			Pattern:         routePattern,
+			Params:          params,
+			Name:            node.Names[method],
+			MiddlewareCount: len(node.Middleware[method]),
+			Doc:             node.Docs[method],
+		})
+	}
+
+	for _, child := range node.Children {
+		routes = collectRoutes(child, pattern, params, routes)
+	}
+
+	for _, child := range node.ParamChildren {
+		routes = collectRoutes(child, pattern, params, routes)
+	}
+
+	if node.WildcardChild != nil {
+		routes = collectRoutes(node.WildcardChild, pattern, params, routes)
+	}
+
+	return routes
+}
@@ -0,0 +1,139 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// Errors returned by Router.URL, wrapped with the route or parameter name
+var (
+	ErrUnknownRoute = errors.New("unknown route")
+	ErrMissingParam = errors.New("missing path param")
+	ErrInvalidParam = errors.New("path param doesn't match its constraint")
+)
+
+// Route is a handler added to a Router, e.g : to name it with
+// router.AddHandler(GET, "/users/{id:int}/settings", h).Name("user-settings")
+type Route struct {
+	Method  HttpMethod
+	Pattern string
+
+	router *Router
+}
+
+// Name registers the route under name for Router.URL, so paths don't have to be hard-coded.
+// Names are unique across the router, naming a route again replaces its previous name.
+// Routes removed since aren't named.
+func (rt *Route) Name(name string) *Route {
+	r := rt.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.namedRoutes[name]; ok && (existing.Pattern != rt.Pattern || existing.Method != rt.Method) {
+		log.Panicf("routing conflict when naming route %s %s, name %s is already used by %s %s", rt.Method, rt.Pattern, name, existing.Method, existing.Pattern)
+	}
+
+	chain := r.copyPath(rt.Pattern, false)
+	if chain == nil {
+		return rt
+	}
+
+	node := chain[len(chain)-1]
+	if _, ok := node.Handlers[rt.Method]; !ok {
+		return rt
+	}
+
+	if previous, ok := node.Names[rt.Method]; ok {
+		delete(r.namedRoutes, previous)
+	}
+
+	if r.namedRoutes == nil {
+		r.namedRoutes = make(map[string]*Route)
+	}
+
+	r.namedRoutes[name] = rt
+	node.Names[rt.Method] = name
+
+	r.root.Store(chain[0])
+
+	return rt
+}
+
+// URL builds the path of the route named name by walking its pattern down the tree, e.g :
+// URL("user-settings", map[string]string{"id": "42"}, nil) is "/users/42/settings".
+// Parameters are escaped and must match their constraint, wildcards keep their slashes.
+// query is appended if not empty.
+func (r *Router) URL(name string, params map[string]string, query url.Values) (string, error) {
+	r.mu.Lock()
+	route, ok := r.namedRoutes[name]
+	r.mu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownRoute, name)
+	}
+
+	segments := strings.Split(route.Pattern, "/")
+	segments[0] = "/"
+
+	built := make([]string, 0, len(segments))
+
+	curNode := r.Root()
+	for _, segment := range segments {
+		if curNode == nil {
+			return "", fmt.Errorf("%w: %s", ErrUnknownRoute, name)
+		}
+
+		if wildcardName, ok := isWildcard(segment); ok {
+			value, ok := params[wildcardName]
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrMissingParam, wildcardName)
+			}
+
+			parts := strings.Split(value, "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+
+			built = append(built, strings.Join(parts, "/"))
+			curNode = curNode.WildcardChild
+			continue
+		}
+
+		if isPathParam(segment) {
+			paramName, constraint := parsePathParam(segment)
+			curNode = curNode.paramChild(constraint)
+
+			value, ok := params[paramName]
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrMissingParam, paramName)
+			}
+
+			// Values are matched as sent, so escaped
+			escaped := url.PathEscape(value)
+			if curNode != nil && !curNode.matchesConstraint(escaped) {
+				return "", fmt.Errorf("%w: %s=%q", ErrInvalidParam, paramName, value)
+			}
+
+			built = append(built, escaped)
+			continue
+		}
+
+		curNode = curNode.Children[r.segmentKey(segment)]
+		built = append(built, segment)
+	}
+
+	if curNode == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnknownRoute, name)
+	}
+
+	// The first segment is the root
+	path := "/" + strings.Join(built[1:], "/")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	return path, nil
+}
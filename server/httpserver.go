@@ -1,24 +1,81 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"io"
 	"log"
 	"net"
 	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
 
-	"github.com/Ciobi0212/httpfromtcp/headers"
+	"github.com/Ciobi0212/httpfromtcp/request"
 	"github.com/Ciobi0212/httpfromtcp/response"
 )
 
+const (
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+)
+
+// States of a tracked connection
+const (
+	connIdle = iota
+	connActive
+)
+
 type Server struct {
 	Port     int
 	Listener net.Listener
 	Router   *response.Router
+
+	// Maximum number of requests served on one connection before it's closed, 0 means no limit
+	MaxRequestsPerConn int
+
+	// How long a persistent connection waits for the next request, 0 means forever
+	IdleTimeout time.Duration
+
+	// How long a client has to send the request line and headers, 0 falls back to ReadTimeout
+	ReadHeaderTimeout time.Duration
+
+	// How long a client has to send the whole request, body included, 0 means forever
+	ReadTimeout time.Duration
+
+	// How long a handler has to write its response, 0 means forever
+	WriteTimeout time.Duration
+
+	// Size limits enforced while parsing requests
+	Limits request.Limits
+
+	// If true, request bodies are not buffered and handlers read them from Request.BodyReader
+	StreamRequestBody bool
+
 	isClosed atomic.Bool
+
+	// Every open connection and whether it's currently serving a request
+	mu      sync.Mutex
+	conns   map[net.Conn]int
+	connsWg sync.WaitGroup
 }
 
+// Serve listens on port and accepts connections right away, so the settings can't be changed
+// safely afterwards. Use NewServer and Start to configure the server first.
 func Serve(port int) (*Server, error) {
+	server, err := NewServer(port)
+	if err != nil {
+		return nil, err
+	}
+
+	server.Start()
+
+	return server, nil
+}
+
+// NewServer listens on port with the default settings, 0 picking a free port. Connections are
+// only accepted once Start is called, the settings must be changed before that.
+func NewServer(port int) (*Server, error) {
 	addr := ":" + strconv.Itoa(port)
 
 	listener, err := net.Listen("tcp", addr)
@@ -27,16 +84,21 @@ func Serve(port int) (*Server, error) {
 		return nil, err
 	}
 
-	server := Server{
-		Port:     port,
-		Listener: listener,
-		isClosed: atomic.Bool{},
-		Router:   response.NewRouter(),
-	}
-
-	go server.listen()
+	return &Server{
+		Port:              listener.Addr().(*net.TCPAddr).Port,
+		Listener:          listener,
+		isClosed:          atomic.Bool{},
+		Router:            response.NewRouter(),
+		IdleTimeout:       DefaultIdleTimeout,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		Limits:            request.DefaultLimits(),
+		conns:             make(map[net.Conn]int),
+	}, nil
+}
 
-	return &server, nil
+// Start accepts connections in the background, it must only be called once
+func (s *Server) Start() {
+	go s.listen()
 }
 
 func (s *Server) Close() error {
@@ -44,6 +106,45 @@ func (s *Server) Close() error {
 	return s.Listener.Close()
 }
 
+// Shutdown stops accepting connections, closes the idle ones and waits for the requests
+// in flight to finish. If ctx is done first, the remaining connections are closed
+// forcefully and the context's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.isClosed.Swap(true)
+
+	err := s.Listener.Close()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+
+	s.mu.Lock()
+	for conn, state := range s.conns {
+		if state == connIdle {
+			conn.Close()
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.connsWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
 func (s *Server) listen() {
 	for {
 		log.Println("Listening for connection")
@@ -61,11 +162,177 @@ func (s *Server) listen() {
 
 		log.Println("Connection received")
 
-		responseWriter := response.ResponseWriter{
-			Conn:    conn,
-			Headers: headers.NewHeaders(),
+		if !s.trackConn(conn) {
+			conn.Close()
+			continue
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn serves requests from conn until the client or a handler asks
+// for the connection to be closed, or a limit configured on the server is hit
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.untrackConn(conn)
+
+	// Bodies are always streamed so the header and body phases get separate deadlines
+	reader := request.NewRequestReader(conn)
+	reader.StreamBody = true
+	reader.Limits = s.Limits
+
+	for served := 0; s.MaxRequestsPerConn <= 0 || served < s.MaxRequestsPerConn; served++ {
+		if !s.setConnState(conn, connIdle) {
+			return
+		}
+
+		// A new connection has to start sending its request within the header timeout,
+		// a persistent one can stay idle between requests for IdleTimeout
+		start := time.Now()
+		if served == 0 {
+			setReadDeadline(conn, start, s.headerTimeout())
+		} else {
+			setReadDeadline(conn, start, s.IdleTimeout)
+		}
+
+		err := reader.Peek()
+		if err != nil {
+			logConnError(conn, err)
+			return
+		}
+
+		s.setConnState(conn, connActive)
+
+		if served > 0 {
+			start = time.Now()
+			setReadDeadline(conn, start, s.headerTimeout())
 		}
 
-		go s.Router.Handle(responseWriter)
+		req, err := reader.ReadRequest()
+		if err != nil {
+			s.respondToReadError(conn, err)
+			return
+		}
+
+		setReadDeadline(conn, start, s.ReadTimeout)
+
+		if !s.StreamRequestBody {
+			err := req.ReadBody()
+			if err != nil {
+				s.respondToReadError(conn, err)
+				return
+			}
+		}
+
+		keepAlive := s.shouldKeepAlive(req, served+1)
+		res := response.NewResponseWriter(conn, keepAlive)
+
+		setWriteDeadline(conn, s.WriteTimeout)
+
+		s.Router.Handle(res, req)
+
+		if err := res.Finish(); err != nil {
+			log.Printf("Error finishing response to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		if !keepAlive || !res.KeepAlive() {
+			return
+		}
+	}
+}
+
+// respondToReadError lets the router answer requests that were too slow, too large
+// or malformed. If the client is gone, the connection is just closed.
+func (s *Server) respondToReadError(conn net.Conn, err error) {
+	logConnError(conn, err)
+
+	// The read deadline might have passed, but the response still has to go through
+	setWriteDeadline(conn, s.WriteTimeout)
+
+	res := response.NewResponseWriter(conn, false)
+	s.Router.HandleReadError(res, err)
+}
+
+// ReadHeaderTimeout falls back to ReadTimeout, as the headers are part of the request
+func (s *Server) headerTimeout() time.Duration {
+	if s.ReadHeaderTimeout > 0 {
+		return s.ReadHeaderTimeout
+	}
+
+	return s.ReadTimeout
+}
+
+func (s *Server) shouldKeepAlive(req *request.Request, served int) bool {
+	if s.isClosed.Load() || req.Headers.HasToken("connection", "close") {
+		return false
+	}
+
+	return s.MaxRequestsPerConn <= 0 || served < s.MaxRequestsPerConn
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed.Load() {
+		return false
+	}
+
+	s.conns[conn] = connActive
+	s.connsWg.Add(1)
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	conn.Close()
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	s.connsWg.Done()
+}
+
+// setConnState returns false if the connection is going idle while the server
+// is shutting down, in which case it shouldn't wait for another request
+func (s *Server) setConnState(conn net.Conn, state int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state == connIdle && s.isClosed.Load() {
+		return false
 	}
+
+	s.conns[conn] = state
+	return true
+}
+
+func setReadDeadline(conn net.Conn, start time.Time, timeout time.Duration) {
+	if timeout > 0 {
+		conn.SetReadDeadline(start.Add(timeout))
+	} else {
+		conn.SetReadDeadline(time.Time{})
+	}
+}
+
+func setWriteDeadline(conn net.Conn, timeout time.Duration) {
+	if timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+	} else {
+		conn.SetWriteDeadline(time.Time{})
+	}
+}
+
+func logConnError(conn net.Conn, err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || isTimeout(err) {
+		return
+	}
+
+	log.Printf("Error handling connection from %s: %v", conn.RemoteAddr(), err)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
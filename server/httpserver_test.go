@@ -0,0 +1,294 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Ciobi0212/httpfromtcp/request"
+	"github.com/Ciobi0212/httpfromtcp/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestServer serves on a free port, configure runs before the first connection is accepted
+func startTestServer(t *testing.T, configure func(s *Server)) *Server {
+	s, err := NewServer(0)
+	require.NoError(t, err)
+
+	s.Router.AddHandler(response.GET, "/", func(w response.ResponseWriter, req *request.Request) *response.HandlerError {
+		w.Headers.Add("Content-Length", "2")
+		w.WriteHeaders(response.Ok)
+		w.WriteBody([]byte("ok"))
+		return nil
+	})
+	if configure != nil {
+		configure(s)
+	}
+
+	s.Start()
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func dial(t *testing.T, s *Server) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", testAddr(s))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, bufio.NewReader(conn)
+}
+
+func testAddr(s *Server) string {
+	return "127.0.0.1:" + strconv.Itoa(s.Port)
+}
+
+func sendRequest(t *testing.T, conn net.Conn, target string) {
+	_, err := io.WriteString(conn, "GET "+target+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+}
+
+func readResponse(t *testing.T, reader *bufio.Reader) *http.Response {
+	res, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	return res
+}
+
+// assertClosed checks the server closed conn within timeout
+func assertClosed(t *testing.T, conn net.Conn, reader *bufio.Reader, timeout time.Duration) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	_, err := reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestShutdown_ClosesIdleConnections(t *testing.T) {
+	s := startTestServer(t, nil)
+	conn, reader := dial(t, s)
+
+	// Test: Keep-alive connection waiting for its next request
+	sendRequest(t, conn, "/")
+	res := readResponse(t, reader)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.False(t, res.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, s.Shutdown(ctx))
+	assertClosed(t, conn, reader, time.Second)
+
+	// Test: New connections are refused
+	_, err := net.DialTimeout("tcp", testAddr(s), 100*time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestShutdown_DrainsRequestsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := startTestServer(t, func(s *Server) {
+		s.Router.AddHandler(response.GET, "/slow", func(w response.ResponseWriter, req *request.Request) *response.HandlerError {
+			close(started)
+			<-release
+			w.Headers.Add("Content-Length", "4")
+			w.WriteHeaders(response.Ok)
+			w.WriteBody([]byte("done"))
+			return nil
+		})
+	})
+	conn, reader := dial(t, s)
+
+	sendRequest(t, conn, "/slow")
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- s.Shutdown(ctx)
+	}()
+
+	// Test: Shutdown waits for the handler
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	// Test: The response goes through, and the connection is closed afterwards
+	res := readResponse(t, reader)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assertClosed(t, conn, reader, time.Second)
+
+	select {
+	case err := <-shutdownErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't return once the request finished")
+	}
+}
+
+func TestShutdown_ForceClosesOnDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	s := startTestServer(t, func(s *Server) {
+		s.Router.AddHandler(response.GET, "/stuck", func(w response.ResponseWriter, req *request.Request) *response.HandlerError {
+			close(started)
+			<-release
+			return nil
+		})
+	})
+	conn, reader := dial(t, s)
+
+	sendRequest(t, conn, "/stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Test: The context's error is returned and the connection is closed under the handler
+	err := s.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assertClosed(t, conn, reader, time.Second)
+}
+
+func TestTimeouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(s *Server)
+	}{
+		{"ReadHeaderTimeout", func(s *Server) { s.ReadHeaderTimeout = 100 * time.Millisecond }},
+		{"ReadHeaderTimeout falls back to ReadTimeout", func(s *Server) {
+			s.ReadHeaderTimeout = 0
+			s.ReadTimeout = 100 * time.Millisecond
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := startTestServer(t, tc.configure)
+			conn, reader := dial(t, s)
+
+			// Test: Headers started but never finished are answered with 408
+			_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n")
+			require.NoError(t, err)
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			res := readResponse(t, reader)
+			assert.Equal(t, http.StatusRequestTimeout, res.StatusCode)
+			assertClosed(t, conn, reader, time.Second)
+		})
+	}
+
+	// Test: Headers sent in time on a persistent connection get the header timeout again
+	t.Run("Header timeout restarts with each request", func(t *testing.T) {
+		s := startTestServer(t, func(s *Server) { s.ReadHeaderTimeout = 200 * time.Millisecond })
+		conn, reader := dial(t, s)
+
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			sendRequest(t, conn, "/")
+			res := readResponse(t, reader)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		s := startTestServer(t, func(s *Server) {
+			s.ReadHeaderTimeout = 5 * time.Second
+			s.IdleTimeout = 100 * time.Millisecond
+		})
+		conn, reader := dial(t, s)
+
+		sendRequest(t, conn, "/")
+		res := readResponse(t, reader)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		// Test: An idle persistent connection is closed silently, well before the header timeout
+		start := time.Now()
+		assertClosed(t, conn, reader, 2*time.Second)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestKeepAlive(t *testing.T) {
+	t.Run("MaxRequestsPerConn", func(t *testing.T) {
+		s := startTestServer(t, func(s *Server) { s.MaxRequestsPerConn = 2 })
+		conn, reader := dial(t, s)
+
+		sendRequest(t, conn, "/")
+		res := readResponse(t, reader)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.False(t, res.Close)
+
+		// Test: The last allowed response asks the client to close, then the connection is closed
+		sendRequest(t, conn, "/")
+		res = readResponse(t, reader)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.True(t, res.Close)
+		assertClosed(t, conn, reader, time.Second)
+	})
+
+	t.Run("Client asks to close", func(t *testing.T) {
+		s := startTestServer(t, nil)
+		conn, reader := dial(t, s)
+
+		// Test: The response is sent, then the connection is closed
+		_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+		require.NoError(t, err)
+
+		res := readResponse(t, reader)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.True(t, res.Close)
+		assertClosed(t, conn, reader, time.Second)
+	})
+
+	t.Run("Pipelined requests", func(t *testing.T) {
+		s := startTestServer(t, func(s *Server) {
+			s.Router.AddHandler(response.GET, "/{name}", func(w response.ResponseWriter, req *request.Request) *response.HandlerError {
+				name := req.PathParams["name"]
+				w.Headers.Add("Content-Length", strconv.Itoa(len(name)))
+				w.WriteHeaders(response.Ok)
+				w.WriteBody([]byte(name))
+				return nil
+			})
+		})
+		conn, reader := dial(t, s)
+
+		// Test: Requests sent in a single write are answered in order
+		names := []string{"first", "second", "third"}
+		pipelined := ""
+		for _, name := range names {
+			pipelined += "GET /" + name + " HTTP/1.1\r\nHost: localhost\r\n\r\n"
+		}
+		_, err := io.WriteString(conn, pipelined)
+		require.NoError(t, err)
+
+		for _, name := range names {
+			res, err := http.ReadResponse(reader, nil)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, name, string(body))
+		}
+	})
+}